@@ -3,9 +3,12 @@
 package blockchain
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
 	"net/url"
 	"strings"
 )
@@ -17,20 +20,116 @@ var blockchains = []string{
 }
 
 type Params struct {
-	Endpoint   string   `json:"endpoint"`
-	Addresses  []string `json:"addresses"`
-	Topics     []string `json:"eventTopics"`
+	Endpoint  string   `json:"endpoint"`
+	Addresses []string `json:"addresses"`
+	Topics    []string `json:"eventTopics"`
+	// AccountIDs are the Substrate oracle accounts, given as hex
+	// or as SS58 addresses of the network of the endpoint.
 	AccountIDs []string `json:"accountIds"`
+	// TopicFilter matches topics by position, as with the "topics"
+	// filter of "eth_getLogs". A null or empty position matches any
+	// topic. Takes precedence over Topics.
+	TopicFilter   [][]string `json:"topicFilter"`
+	Confirmations uint64     `json:"confirmations"`
+	// Event is either a JSON ABI fragment of an event, or a
+	// human-readable signature such as "Transfer(address,address,uint256)".
+	// Unless anonymous, its signature is the first topic, which other
+	// topics cannot be given for. For Substrate, it is the name of the
+	// event of the Pallet.
+	Event json.RawMessage `json:"event,omitempty"`
+	// Kind is the kind of Ethereum subscription, e.g. "runlog".
+	// Plain log subscriptions leave it empty.
+	Kind string `json:"kind"`
+	// Strategy is how Ethereum logs are fetched, e.g. "heads".
+	// Subscribing to logs directly leaves it empty.
+	Strategy string `json:"strategy"`
+	// Function is the contract function polled by the "call" kind, given
+	// as a JSON ABI fragment or a human-readable signature, with its Args.
+	Function  json.RawMessage `json:"function,omitempty"`
+	Args      []string        `json:"args"`
+	Threshold string          `json:"threshold"`
+	// Cooldown is the number of blocks before an
+	// upkeep can trigger the job again.
+	Cooldown uint64 `json:"cooldown"`
+	// Receipts adds the status, sender and value of the transaction
+	// to each log event. Logs can also be filtered on these: on a
+	// successful status, on any of the TxFrom senders, and on a
+	// MinValue in wei. Filtering implies Receipts.
+	Receipts       bool     `json:"receipts"`
+	RequireSuccess bool     `json:"requireSuccess"`
+	TxFrom         []string `json:"txFrom"`
+	MinValue       string   `json:"minValue"`
+	// BlockHeaders adds the timestamp, base fee
+	// and miner of the block to each log event.
+	BlockHeaders bool `json:"blockHeaders"`
+	// Factory is the address of a factory contract. The addresses of
+	// the contracts it deploys are added to the subscription. They are
+	// extracted from the FactoryEvent creation event, at the FactoryChild
+	// location: either "topic:<index>" or "data:<byte offset>".
+	Factory      string          `json:"factory"`
+	FactoryEvent json.RawMessage `json:"factoryEvent,omitempty"`
+	FactoryChild string          `json:"factoryChild"`
+	// Selectors are the 4-byte function selectors
+	// matched by the "trace" kind, e.g. "0xa9059cbb".
+	Selectors []string `json:"selectors"`
+	// Deviation is the percentage a value must move from the last
+	// forwarded value to trigger the job, e.g. "0.5". Heartbeat is
+	// the number of seconds after which the job is triggered anyway.
+	// The value is the decoded event Field, or the Field returned by
+	// the "call" kind, which defaults to the first return value.
+	Deviation string `json:"deviation"`
+	Heartbeat uint64 `json:"heartbeat"`
+	Field     string `json:"field"`
+	// Token is the token contract of the "erc20" and "erc721" kinds.
+	// Transfers are matched from any of From, to any of To, and for
+	// the "erc20" kind, of at least MinAmount.
+	Token     string   `json:"token"`
+	From      []string `json:"from"`
+	To        []string `json:"to"`
+	MinAmount string   `json:"minAmount"`
+	// Pallet is the name of the Substrate pallet emitting the events,
+	// e.g. "Chainlink", and Event the name of the event, e.g.
+	// "OracleRequest". Events are matched on each Filter arg, named
+	// "arg0", "arg1", etc. Types defines the arg types the runtime
+	// metadata does not, e.g. {"RequestId": "u64"}. Leaving Pallet
+	// empty matches Chainlink OracleRequests for the job.
+	Pallet string            `json:"pallet"`
+	Filter map[string]string `json:"filter"`
+	Types  map[string]string `json:"types"`
+	// Finalized only reads the events of
+	// Substrate blocks once they are finalized.
+	Finalized bool `json:"finalized"`
+}
+
+// StateStorer persists the state blockchain managers need
+// to resume a subscription after a restart.
+type StateStorer interface {
+	LoadBlockCursor(job string) (*store.BlockCursor, error)
+	SaveBlockCursor(cursor *store.BlockCursor) error
+	LoadFactoryAddresses(job string) ([]string, error)
+	SaveFactoryAddress(job, address string) error
+	LoadDeviationState(job string) (*store.DeviationState, error)
+	SaveDeviationState(state *store.DeviationState) error
+	LoadSubscriptionState(job string) (*store.SubscriptionState, error)
+	SaveSubscriptionState(state *store.SubscriptionState) error
 }
 
 // CreateJsonManager creates a new instance of a JSON blockchain manager with the provided
-// connection type and store.Subscription config.
-func CreateJsonManager(t subscriber.Type, sub store.Subscription) (subscriber.JsonManager, error) {
+// connection type, store.Subscription config and StateStorer.
+func CreateJsonManager(t subscriber.Type, sub store.Subscription, db StateStorer) (subscriber.JsonManager, error) {
 	switch sub.Endpoint.Type {
 	case ETH:
-		return createEthManager(t, sub), nil
+		switch sub.Ethereum.Kind {
+		case EthCall:
+			return createEthCallManager(t, sub, db)
+		case EthUpkeep:
+			return createEthUpkeepManager(t, sub)
+		case EthTrace:
+			return createEthTraceManager(t, sub)
+		}
+		return createEthManager(t, sub, db)
 	case Substrate:
-		return createSubstrateManager(t, sub)
+		return createSubstrateManager(t, sub, db)
 	}
 
 	return nil, errors.New("unknown blockchain type for JSON manager")
@@ -58,7 +157,8 @@ func GetConnectionType(endpoint store.Endpoint) (subscriber.Type, error) {
 			return subscriber.Unknown, err
 		}
 
-		if strings.HasPrefix(u.Scheme, "ws") {
+		// IPC endpoints are used the same way as WS endpoints
+		if strings.HasPrefix(u.Scheme, "ws") || u.Scheme == "ipc" {
 			return subscriber.WS, nil
 		} else if strings.HasPrefix(u.Scheme, "http") {
 			return subscriber.RPC, nil
@@ -81,7 +181,7 @@ func GetValidations(t string, params Params) []int {
 	switch t {
 	case ETH:
 		return []int{
-			len(params.Addresses) + len(params.Topics),
+			len(params.Addresses) + len(params.Topics) + len(params.TopicFilter) + len(rawParamToString(params.Event)) + len(params.Factory) + len(params.Selectors) + len(params.Token) + len(params.From) + len(params.To),
 		}
 	case XTZ:
 		return []int{
@@ -89,19 +189,85 @@ func GetValidations(t string, params Params) []int {
 		}
 	case Substrate:
 		return []int{
-			len(params.AccountIDs),
+			len(params.AccountIDs) + len(params.Pallet),
 		}
 	}
 
 	return nil
 }
 
+// ValidationError is returned when the params of a subscription do
+// not fit the chain it subscribes to, e.g. an address of another network,
+// as opposed to failing to reach the chain. The job creator can fix it.
+type ValidationError struct {
+	Err error
+}
+
+func (e ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// ValidateParams checks the params provided for the endpoint, so that
+// a job with invalid params is rejected when it is created, rather than
+// subscribing with a partial filter. The params are parsed the same way
+// as when subscribing, e.g. the ABIs, topics and args of Ethereum jobs.
+func ValidateParams(endpoint store.Endpoint, params Params) error {
+	switch endpoint.Type {
+	case ETH:
+	case Substrate:
+		for _, id := range params.AccountIDs {
+			if _, _, err := parseSubstrateAccountID(id); err != nil {
+				return fmt.Errorf("invalid account ID %s: %v", id, err)
+			}
+		}
+	default:
+		return nil
+	}
+
+	// An invalid endpoint is not for the job creator
+	// to fix, and fails when subscribing instead
+	t, err := GetConnectionType(endpoint)
+	if err != nil {
+		return nil
+	}
+
+	sub := store.Subscription{Endpoint: endpoint}
+	CreateSubscription(&sub, params)
+	_, err = CreateJsonManager(t, sub, nil)
+	return err
+}
+
 func CreateSubscription(sub *store.Subscription, params Params) {
 	switch sub.Endpoint.Type {
 	case ETH:
 		sub.Ethereum = store.EthSubscription{
-			Addresses: params.Addresses,
-			Topics:    params.Topics,
+			Addresses:      params.Addresses,
+			Topics:         params.Topics,
+			TopicFilter:    params.TopicFilter,
+			Confirmations:  params.Confirmations,
+			EventABI:       rawParamToString(params.Event),
+			Kind:           params.Kind,
+			Strategy:       params.Strategy,
+			Function:       rawParamToString(params.Function),
+			Args:           params.Args,
+			Threshold:      params.Threshold,
+			Cooldown:       params.Cooldown,
+			Receipts:       params.Receipts,
+			RequireSuccess: params.RequireSuccess,
+			TxFrom:         params.TxFrom,
+			MinValue:       params.MinValue,
+			BlockHeaders:   params.BlockHeaders,
+			Factory:        params.Factory,
+			FactoryEvent:   rawParamToString(params.FactoryEvent),
+			FactoryChild:   params.FactoryChild,
+			Selectors:      params.Selectors,
+			Deviation:      params.Deviation,
+			Heartbeat:      params.Heartbeat,
+			Field:          params.Field,
+			Token:          params.Token,
+			TransferFrom:   params.From,
+			TransferTo:     params.To,
+			MinAmount:      params.MinAmount,
 		}
 	case XTZ:
 		sub.Tezos = store.TezosSubscription{
@@ -110,6 +276,114 @@ func CreateSubscription(sub *store.Subscription, params Params) {
 	case Substrate:
 		sub.Substrate = store.SubstrateSubscription{
 			AccountIds: params.AccountIDs,
+			Pallet:     params.Pallet,
+			Event:      rawParamToString(params.Event),
+			Filter:     params.Filter,
+			Types:      params.Types,
+			Finalized:  params.Finalized,
+		}
+	}
+}
+
+// callJsonRpc sends a single JSON-RPC request using the caller provided,
+// and unmarshals the result of the response into result.
+func callJsonRpc(caller subscriber.Caller, method string, params interface{}, result interface{}) error {
+	msg := jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  method,
+	}
+
+	if params != nil {
+		bz, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = bz
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := caller.Call(payload)
+	if err != nil {
+		return err
+	}
+
+	var res jsonrpcMessage
+	if err := json.Unmarshal(resp, &res); err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%s returned error: %v", method, *res.Error)
+	}
+
+	return json.Unmarshal(res.Result, result)
+}
+
+// cursorTracker keeps track of the store.BlockCursor of a subscription,
+// and persists it as the subscription progresses. A nil cursorTracker
+// is valid, and does not track anything.
+type cursorTracker struct {
+	db     StateStorer
+	job    string
+	cursor *store.BlockCursor // nil until a cursor has been stored
+}
+
+// newCursorTracker loads the cursor stored for the job provided.
+// Returns nil if db is nil.
+func newCursorTracker(db StateStorer, job string) *cursorTracker {
+	if db == nil {
+		return nil
+	}
+
+	cursor, err := db.LoadBlockCursor(job)
+	if err != nil {
+		log.Println("failed loading block cursor:", err)
+	}
+
+	return &cursorTracker{db: db, job: job, cursor: cursor}
+}
+
+// get returns the current cursor, or nil if none has been stored.
+func (t *cursorTracker) get() *store.BlockCursor {
+	if t == nil {
+		return nil
+	}
+	return t.cursor
+}
+
+// advance moves the cursor forward to the position provided, and
+// persists it. Positions behind the current cursor are ignored.
+func (t *cursorTracker) advance(blockNumber, logIndex uint64) {
+	if t == nil {
+		return
+	}
+
+	if c := t.cursor; c != nil {
+		if blockNumber < c.BlockNumber || (blockNumber == c.BlockNumber && logIndex <= c.LogIndex) {
+			return
 		}
 	}
+
+	cursor := &store.BlockCursor{Job: t.job, BlockNumber: blockNumber, LogIndex: logIndex}
+	if err := t.db.SaveBlockCursor(cursor); err != nil {
+		log.Println("failed saving block cursor:", err)
+	}
+	t.cursor = cursor
+}
+
+// processed returns whether the log at the block number and
+// log index provided has already been processed.
+func (t *cursorTracker) processed(blockNumber, logIndex uint64) bool {
+	c := t.get()
+	if c == nil {
+		return false
+	}
+	if blockNumber == c.BlockNumber+1 {
+		return logIndex < c.LogIndex
+	}
+	return blockNumber <= c.BlockNumber
 }
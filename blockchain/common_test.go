@@ -1,11 +1,83 @@
 package blockchain
 
 import (
+	"encoding/json"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
 	"testing"
 )
 
+// mockCaller responds to JSON-RPC requests with the value returned from
+// the function, or with a JSON-RPC error if the function returns an error.
+type mockCaller func(msg jsonrpcMessage) (interface{}, error)
+
+func (c mockCaller) Call(payload []byte) ([]byte, error) {
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil, err
+	}
+
+	res := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+	}
+
+	result, err := c(msg)
+	if err != nil {
+		res["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+	} else {
+		res["result"] = result
+	}
+
+	return json.Marshal(res)
+}
+
+// mockStateStorer keeps the state of a subscription in memory.
+type mockStateStorer struct {
+	cursor    *store.BlockCursor
+	saves     int
+	addresses []string
+	deviation *store.DeviationState
+	state     *store.SubscriptionState
+}
+
+func (m *mockStateStorer) LoadBlockCursor(string) (*store.BlockCursor, error) {
+	return m.cursor, nil
+}
+
+func (m *mockStateStorer) SaveBlockCursor(cursor *store.BlockCursor) error {
+	m.cursor = cursor
+	m.saves++
+	return nil
+}
+
+func (m *mockStateStorer) LoadFactoryAddresses(string) ([]string, error) {
+	return m.addresses, nil
+}
+
+func (m *mockStateStorer) SaveFactoryAddress(_, address string) error {
+	m.addresses = append(m.addresses, address)
+	return nil
+}
+
+func (m *mockStateStorer) LoadDeviationState(string) (*store.DeviationState, error) {
+	return m.deviation, nil
+}
+
+func (m *mockStateStorer) SaveDeviationState(state *store.DeviationState) error {
+	m.deviation = state
+	return nil
+}
+
+func (m *mockStateStorer) LoadSubscriptionState(string) (*store.SubscriptionState, error) {
+	return m.state, nil
+}
+
+func (m *mockStateStorer) SaveSubscriptionState(state *store.SubscriptionState) error {
+	m.state = state
+	return nil
+}
+
 func Test_GetConnectionType(t *testing.T) {
 	type args struct {
 		rawUrl string
@@ -40,6 +112,12 @@ func Test_GetConnectionType(t *testing.T) {
 			subscriber.WS,
 			false,
 		},
+		{
+			"returns WS on ipc://",
+			args{"ipc:///home/geth/.ethereum/geth.ipc"},
+			subscriber.WS,
+			false,
+		},
 		{
 			"returns RPC on http://",
 			args{"http://localhost/"},
@@ -72,3 +150,79 @@ func Test_GetConnectionType(t *testing.T) {
 		})
 	}
 }
+
+func Test_cursorTracker(t *testing.T) {
+	t.Run("nil tracker does nothing", func(t *testing.T) {
+		var tracker *cursorTracker
+		tracker.advance(1, 0)
+		if tracker.get() != nil || tracker.processed(0, 0) {
+			t.Error("expected nil tracker to not track anything")
+		}
+	})
+
+	t.Run("only advances forward", func(t *testing.T) {
+		db := &mockStateStorer{}
+		tracker := newCursorTracker(db, "job")
+		if tracker.get() != nil {
+			t.Fatal("expected no cursor before advancing")
+		}
+
+		tracker.advance(5, 2)
+		tracker.advance(5, 1)
+		tracker.advance(4, 9)
+		tracker.advance(5, 2)
+		if db.saves != 1 || db.cursor.BlockNumber != 5 || db.cursor.LogIndex != 2 || db.cursor.Job != "job" {
+			t.Errorf("unexpected cursor %+v after %d saves", db.cursor, db.saves)
+		}
+
+		tracker.advance(6, 0)
+		if db.saves != 2 || db.cursor.BlockNumber != 6 {
+			t.Errorf("unexpected cursor %+v after %d saves", db.cursor, db.saves)
+		}
+	})
+
+	t.Run("checks processed logs", func(t *testing.T) {
+		tracker := newCursorTracker(&mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 5, LogIndex: 2}}, "job")
+		tests := []struct {
+			blockNumber, logIndex uint64
+			want                  bool
+		}{
+			{4, 10, true},
+			{5, 10, true},
+			{6, 1, true},
+			{6, 2, false},
+			{7, 0, false},
+		}
+		for _, tt := range tests {
+			if got := tracker.processed(tt.blockNumber, tt.logIndex); got != tt.want {
+				t.Errorf("processed(%d, %d) = %v, want %v", tt.blockNumber, tt.logIndex, got, tt.want)
+			}
+		}
+	})
+}
+
+func Test_ValidateParams(t *testing.T) {
+	rpc := store.Endpoint{Type: ETH, Url: "http://localhost:8545"}
+	tests := []struct {
+		name     string
+		endpoint store.Endpoint
+		params   Params
+		wantErr  bool
+	}{
+		{"valid event", rpc, Params{Addresses: []string{"0x1"}, Event: json.RawMessage(`"Transfer(address,address,uint256)"`)}, false},
+		{"first topic other than the event signature", rpc, Params{Event: json.RawMessage(`"Transfer(address,address,uint256)"`), TopicFilter: [][]string{{"0x1"}}}, true},
+		{"invalid event ABI", rpc, Params{Event: json.RawMessage(`"Transfer(address"`)}, true},
+		{"unknown kind", rpc, Params{Addresses: []string{"0x1"}, Kind: "unknown"}, true},
+		{"strategy of another connection", rpc, Params{Addresses: []string{"0x1"}, Strategy: EthHeads}, true},
+		{"arg out of range", rpc, Params{Addresses: []string{"0x1"}, Kind: EthCall, Function: json.RawMessage(`"balanceOf(uint8 id) returns (uint256)"`), Args: []string{"256"}}, true},
+		{"invalid Substrate account ID", store.Endpoint{Type: Substrate, Url: "ws://localhost:9944"}, Params{AccountIDs: []string{"0x123"}}, true},
+		{"invalid endpoint", store.Endpoint{Type: ETH}, Params{Addresses: []string{"0x1"}, Kind: "unknown"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateParams(tt.endpoint, tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,49 +2,218 @@ package blockchain
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/smartcontractkit/external-initiator/subscriber"
 	"log"
 	"math/big"
+	"sort"
+	"strings"
 )
 
 const ETH = "ethereum"
 
+// EthHeads is the strategy of Ethereum subscriptions that subscribe
+// to new heads, and fetch the logs of every new block.
+const EthHeads = "heads"
+
+// EthFilter is the strategy of Ethereum subscriptions that install
+// a filter on the node, and poll it for changes.
+const EthFilter = "filter"
+
 // The EthManager implements the subscriber.JsonManager interface and allows
 // for interacting with ETH nodes over RPC or WS.
 type EthManager struct {
-	fq *filterQuery
-	p  subscriber.Type
+	fq            *filterQuery
+	p             subscriber.Type
+	caller        subscriber.Caller
+	confirmations uint64
+	queue         *confirmationQueue
+	retry         *retryQueue
+	cursor        *cursorTracker
+	backfilled    map[string]bool
+	event         *abi.Event
+	kind          string
+	specIDs       []common.Hash
+	logRange      *logRange
+	strategy      string
+	heads         *headTracker
+	filter        *logFilter
+	txFilter      *txFilter
+	blocks        *blockCache
+	factory       *factoryTracker
+	bloom         *bloomStats
+	deviation     *deviationTrigger
+	transfer      *transferPreset
 }
 
 // createEthManager creates a new instance of EthManager with the provided
-// connection type and store.EthSubscription config.
-func createEthManager(p subscriber.Type, config store.Subscription) EthManager {
+// connection type and store.EthSubscription config. The progress of the
+// subscription is persisted using db, if provided.
+//
+// If the subscription has an event ABI, the logs are decoded using it,
+// and the event signature is used as the first topic. Subscriptions of
+// the EthRunLog kind only match OracleRequest events for their job.
+// Subscriptions following a factory also match the contracts deployed
+// by the factory. Subscriptions with a deviation or a heartbeat only
+// forward logs when the decoded field should trigger the job.
+func createEthManager(p subscriber.Type, config store.Subscription, db StateStorer) (EthManager, error) {
 	var addresses []common.Address
 	for _, a := range config.Ethereum.Addresses {
 		addresses = append(addresses, common.HexToAddress(a))
 	}
 
-	var topics [][]common.Hash
-	var t []common.Hash
-	for _, value := range config.Ethereum.Topics {
-		if len(value) < 1 {
-			continue
+	topics := topicsFromConfig(config.Ethereum)
+
+	var event *abi.Event
+	var specIDs []common.Hash
+	var transfer *transferPreset
+	switch config.Ethereum.Kind {
+	case "":
+		if config.Ethereum.EventABI == "" {
+			break
+		}
+
+		var err error
+		event, err = parseEventABI(config.Ethereum.EventABI)
+		if err != nil {
+			return EthManager{}, fmt.Errorf("invalid event ABI: %v", err)
+		}
+
+		if !event.Anonymous {
+			// The first topic of the event is its signature
+			if first := topics[0]; len(first) > 0 && (len(first) > 1 || first[0] != event.ID()) {
+				return EthManager{}, fmt.Errorf("the first topic must be the event signature %s", event.ID().Hex())
+			}
+			topics[0] = []common.Hash{event.ID()}
+		}
+	case EthRunLog:
+		specIDs = runLogSpecIDs(config.Job)
+		topics = [][]common.Hash{{oracleRequestEvent.ID()}, specIDs}
+	case EthErc20, EthErc721:
+		var err error
+		transfer, err = newTransferPreset(config.Ethereum)
+		if err != nil {
+			return EthManager{}, err
+		}
+		event = transfer.event
+		addresses = append(addresses, transfer.addresses...)
+		topics = transfer.topics
+	default:
+		return EthManager{}, fmt.Errorf("unknown Ethereum subscription kind: %s", config.Ethereum.Kind)
+	}
+
+	switch config.Ethereum.Strategy {
+	case "":
+	case EthHeads:
+		if p != subscriber.WS {
+			return EthManager{}, errors.New("the heads strategy requires a WebSocket endpoint")
+		}
+	case EthFilter:
+		if p != subscriber.RPC {
+			return EthManager{}, errors.New("the filter strategy requires an RPC endpoint")
+		}
+	case EthBloom:
+		if p != subscriber.RPC {
+			return EthManager{}, errors.New("the bloom strategy requires an RPC endpoint")
+		}
+	default:
+		return EthManager{}, fmt.Errorf("unknown Ethereum subscription strategy: %s", config.Ethereum.Strategy)
+	}
+
+	txFilter, err := newTxFilter(config.Ethereum)
+	if err != nil {
+		return EthManager{}, err
+	}
+
+	var blocks *blockCache
+	if config.Ethereum.BlockHeaders {
+		blocks = newBlockCache()
+	}
+
+	fq := &filterQuery{
+		Addresses: addresses,
+		Topics:    topics,
+	}
+	factory, err := newFactoryTracker(config, addresses, topics, db)
+	if err != nil {
+		return EthManager{}, err
+	}
+	if factory != nil {
+		if p == subscriber.WS && config.Ethereum.Strategy != EthHeads {
+			return EthManager{}, errors.New("following a factory over WebSocket requires the heads strategy")
+		}
+		fq = factory.filterQuery()
+	}
+
+	deviation, err := newDeviationTrigger(config, db)
+	if err != nil {
+		return EthManager{}, err
+	}
+	if deviation != nil {
+		if event == nil {
+			return EthManager{}, errors.New("a deviation or heartbeat requires an event ABI")
+		}
+		if err := deviation.validateField(event.Inputs); err != nil {
+			return EthManager{}, err
 		}
-		t = append(t, common.HexToHash(value))
 	}
-	topics = append(topics, t)
 
 	return EthManager{
-		fq: &filterQuery{
-			Addresses: addresses,
-			Topics:    topics,
-		},
-		p: p,
+		fq:            fq,
+		p:             p,
+		caller:        subscriber.NewCaller(config.Endpoint.Url),
+		confirmations: config.Ethereum.Confirmations,
+		queue:         &confirmationQueue{},
+		retry:         &retryQueue{},
+		cursor:        newCursorTracker(db, config.Job),
+		backfilled:    make(map[string]bool),
+		event:         event,
+		kind:          config.Ethereum.Kind,
+		specIDs:       specIDs,
+		logRange:      &logRange{size: maxLogRange},
+		strategy:      config.Ethereum.Strategy,
+		heads:         &headTracker{},
+		filter:        &logFilter{},
+		txFilter:      txFilter,
+		blocks:        blocks,
+		factory:       factory,
+		bloom:         &bloomStats{},
+		deviation:     deviation,
+		transfer:      transfer,
+	}, nil
+}
+
+// topicsFromConfig returns the positional topic filter of the
+// subscription. Subscriptions without a positional filter match
+// any of their flat list of topics in the first position.
+func topicsFromConfig(config store.EthSubscription) [][]common.Hash {
+	if len(config.TopicFilter) == 0 {
+		return [][]common.Hash{hexToHashes(config.Topics)}
+	}
+
+	var topics [][]common.Hash
+	for _, position := range config.TopicFilter {
+		topics = append(topics, hexToHashes(position))
+	}
+	return topics
+}
+
+// hexToHashes converts hex strings to hashes, ignoring empty strings.
+// Returns nil if there are no hashes, which matches any topic.
+func hexToHashes(values []string) []common.Hash {
+	var hashes []common.Hash
+	for _, value := range values {
+		if len(value) < 1 {
+			continue
+		}
+		hashes = append(hashes, common.HexToHash(value))
 	}
+	return hashes
 }
 
 // GetTriggerJson generates a JSON payload to the ETH node
@@ -52,9 +221,16 @@ func createEthManager(p subscriber.Type, config store.Subscription) EthManager {
 //
 // If EthManager is using WebSocket:
 // Creates a new "eth_subscribe" subscription.
+// If confirmations are required, it is sent in a batch
+// together with a "newHeads" subscription. With the
+// EthHeads strategy, only "newHeads" is subscribed to.
 //
 // If EthManager is using RPC:
-// Sends a "eth_getLogs" request.
+// Sends a "eth_getLogs" request. With the EthFilter
+// strategy, a filter is installed with "eth_newFilter"
+// instead, and then polled with "eth_getFilterChanges".
+// With the EthBloom strategy, the current block
+// number is requested instead.
 func (e EthManager) GetTriggerJson() []byte {
 	if e.p == subscriber.RPC && e.fq.FromBlock == "" {
 		e.fq.FromBlock = "latest"
@@ -79,9 +255,51 @@ func (e EthManager) GetTriggerJson() []byte {
 	case subscriber.WS:
 		msg.Method = "eth_subscribe"
 		msg.Params = json.RawMessage(`["logs",` + string(filterBytes) + `]`)
+
+		// Logs are fetched for every new head instead
+		if e.strategy == EthHeads {
+			msg.Params = json.RawMessage(`["newHeads"]`)
+			break
+		}
+
+		// Held logs are released, and the heartbeat
+		// is checked, as new heads come in
+		if e.confirmations > 0 || e.deviation.hasHeartbeat() {
+			heads := jsonrpcMessage{
+				Version: "2.0",
+				ID:      json.RawMessage(`2`),
+				Method:  "eth_subscribe",
+				Params:  json.RawMessage(`["newHeads"]`),
+			}
+
+			bytes, err := json.Marshal([]jsonrpcMessage{msg, heads})
+			if err != nil {
+				return nil
+			}
+
+			return bytes
+		}
 	case subscriber.RPC:
 		msg.Method = "eth_getLogs"
 		msg.Params = json.RawMessage(`[` + string(filterBytes) + `]`)
+
+		if e.strategy == EthFilter {
+			if e.filter.id == "" {
+				msg.Method = "eth_newFilter"
+				msg.Params, err = json.Marshal([]interface{}{e.newFilterArg()})
+			} else {
+				msg.Method = "eth_getFilterChanges"
+				msg.Params, err = json.Marshal([]string{e.filter.id})
+			}
+			if err != nil {
+				return nil
+			}
+		}
+
+		if e.strategy == EthBloom {
+			msg.Method = "eth_blockNumber"
+			msg.Params = nil
+		}
 	}
 
 	bytes, err := json.Marshal(msg)
@@ -129,6 +347,8 @@ func (e EthManager) GetTestJson() []byte {
 // If EthManager is using RPC:
 // Attempts to parse the block number in the response.
 // If successful, stores the block number in EthManager.
+// If the subscription has a stored block cursor, queries
+// resume from the block after the cursor instead.
 func (e EthManager) ParseTestResponse(data []byte) error {
 	if e.p == subscriber.RPC {
 		var msg jsonrpcMessage
@@ -139,12 +359,86 @@ func (e EthManager) ParseTestResponse(data []byte) error {
 		if err := json.Unmarshal(msg.Result, &res); err != nil {
 			return err
 		}
+
+		if cursor := e.cursor.get(); cursor != nil {
+			e.fq.FromBlock = hexutil.EncodeUint64(cursor.BlockNumber + 1)
+			return nil
+		}
+
 		e.fq.FromBlock = res
+		if head, err := hexutil.DecodeUint64(res); err == nil && head > 0 {
+			e.cursor.advance(head-1, 0)
+		}
 	}
 
 	return nil
 }
 
+// Backfill implements subscriber.Backfiller. It fetches the
+// logs emitted since the stored block cursor using "eth_getLogs",
+// so that no logs are missed while the WS connection was down.
+//
+// If there is no stored cursor, the current block is stored
+// as the cursor instead.
+func (e EthManager) Backfill() []subscriber.Event {
+	if e.cursor == nil {
+		return nil
+	}
+
+	head, err := e.getBlockNumber()
+	if err != nil {
+		log.Println("failed getting block number:", err)
+		return nil
+	}
+
+	cursor := e.cursor.get()
+	if cursor == nil {
+		e.cursor.advance(head, 0)
+		e.heads.last = head
+		return nil
+	}
+	if cursor.BlockNumber >= head {
+		e.heads.last = head
+		return nil
+	}
+
+	// Logs from blocks mined after subscribing could
+	// be sent by the subscription as well
+	for id := range e.backfilled {
+		delete(e.backfilled, id)
+	}
+
+	var released []ethLogResponse
+	count := 0
+	err = e.getLogsInChunks(cursor.BlockNumber+1, head, func(logs []ethLogResponse, toBlock uint64) {
+		released = append(released, e.processing(toBlock)...)
+		for _, evt := range logs {
+			if e.cursor.processed(evt.blockNumber(), evt.logIndex()) {
+				continue
+			}
+			e.backfilled[evt.id()] = true
+			released = append(released, e.handleLog(evt)...)
+		}
+		count += len(logs)
+		e.heads.last = toBlock
+		e.saveProgress(toBlock)
+	})
+	if err != nil {
+		log.Println("failed backfilling logs:", err)
+	}
+
+	if e.confirmations > 0 {
+		released = append(released, e.releaseConfirmed(head)...)
+	}
+
+	if err == nil {
+		log.Printf("Backfilled %d logs from blocks %d to %d\n", count, cursor.BlockNumber+1, head)
+		e.saveProgress(head)
+	}
+
+	return e.logsToEvents(released)
+}
+
 type ethSubscribeResponse struct {
 	Subscription string          `json:"subscription"`
 	Result       json.RawMessage `json:"result"`
@@ -159,16 +453,45 @@ type ethLogResponse struct {
 	Address          string   `json:"address"`
 	Data             string   `json:"data"`
 	Topics           []string `json:"topics"`
+	Removed          bool     `json:"removed,omitempty"`
+}
+
+type ethHeadResponse struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
 }
 
 // ParseResponse parses the response from the
 // ETH node, and returns a slice of subscriber.Events
 // and if the parsing was successful.
 //
+// Logs that have been removed from the chain by a reorg
+// are forwarded with "removed" set to true.
+// If confirmations are required, logs are held until
+// enough blocks have been mined on top of them.
+//
 // If EthManager is using RPC:
 // If there are new events, update EthManager with
-// the latest block number it sees.
+// the latest block number it sees. If the node rejects
+// the query for covering too many blocks, the logs up to
+// the current block are fetched in chunks instead. With
+// the EthBloom strategy, the logsBloom of each new block
+// is checked before fetching its logs.
+//
+// If a heartbeat is configured, it is checked on every
+// response, so that it also passes when no logs come in.
+// Events of a response that failed are dropped by the
+// subscriber, so the heartbeat is then kept for the next one.
 func (e EthManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	events, ok := e.parseResponse(data)
+	if !ok {
+		return events, false
+	}
+	return append(events, e.heartbeat()...), true
+}
+
+func (e EthManager) parseResponse(data []byte) ([]subscriber.Event, bool) {
 	var msg jsonrpcMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		log.Println("failed parsing msg:", msg)
@@ -185,32 +508,113 @@ func (e EthManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 			return nil, false
 		}
 
+		var head ethHeadResponse
+		if err := json.Unmarshal(res.Result, &head); err == nil && head.Number != "" && head.ParentHash != "" {
+			number, err := hexutil.DecodeUint64(head.Number)
+			if err != nil {
+				log.Println("decode head number:", err)
+				return nil, false
+			}
+			var released []ethLogResponse
+			if e.strategy == EthHeads {
+				logs, err := e.fetchHeadLogs(number, common.HexToHash(head.Hash))
+				if err != nil {
+					log.Println("failed fetching logs for head:", err)
+					return nil, true
+				}
+				released = logs
+			}
+
+			released = append(released, e.releaseConfirmed(number)...)
+			// Heads subscribed to for the heartbeat alone may
+			// come in before the logs of their block
+			if (e.strategy == EthHeads || e.confirmations > 0) && number >= e.confirmations {
+				e.saveProgress(number - e.confirmations)
+			}
+			return e.logsToEvents(released), true
+		}
+
 		var evt ethLogResponse
 		if err := json.Unmarshal(res.Result, &evt); err != nil {
 			log.Println("unmarshal:", err)
 			return nil, false
 		}
 
-		event, err := json.Marshal(evt)
-		if err != nil {
-			log.Println("marshal:", err)
-			return nil, false
+		if !evt.Removed && e.backfilled[evt.id()] {
+			return nil, true
 		}
 
-		events = append(events, event)
+		events = e.logsToEvents(e.handleLog(evt))
+
+		// Without confirmations, the log has been forwarded, and
+		// every block before it has been processed
+		_, retrying := e.retry.first()
+		if !evt.Removed && e.confirmations == 0 && evt.blockNumber() > 0 && !retrying {
+			e.cursor.advance(evt.blockNumber()-1, evt.logIndex()+1)
+		}
 
 	case subscriber.RPC:
+		if e.strategy == EthBloom {
+			return e.pollBlooms(msg)
+		}
+
+		if e.strategy == EthFilter && e.filter.id == "" {
+			var id string
+			if err := json.Unmarshal(msg.Result, &id); err != nil || id == "" {
+				log.Println("failed installing filter:", string(data))
+				return nil, false
+			}
+			return e.filterInstalled(id), true
+		}
+
+		if msg.Error != nil {
+			err := fmt.Errorf("eth_getLogs returned error: %v", *msg.Error)
+			if e.strategy == EthFilter {
+				err = fmt.Errorf("eth_getFilterChanges returned error: %v", *msg.Error)
+			}
+
+			switch {
+			case e.strategy == EthFilter && isFilterNotFoundError(err):
+				// The node dropped the filter, e.g.
+				// after a restart or a period of inactivity
+				log.Println("Reinstalling filter:", err)
+				return e.reinstallFilter(), true
+			case e.strategy != EthFilter && isLogRangeError(err):
+				events, err := e.catchUp()
+				if err != nil {
+					log.Println("failed catching up on logs:", err)
+				}
+				return events, true
+			}
+
+			log.Println(err)
+			return nil, false
+		}
+
 		var rawEvents []ethLogResponse
 		if err := json.Unmarshal(msg.Result, &rawEvents); err != nil {
 			return nil, false
 		}
 
+		// "fromBlock" is moved past the last block with logs,
+		// so later blocks are queried again with new children
+		var last uint64
 		for _, evt := range rawEvents {
-			event, err := json.Marshal(evt)
-			if err != nil {
+			if n := evt.blockNumber(); n > last {
+				last = n
+			}
+		}
+		handled := e.processing(last)
+		for _, evt := range rawEvents {
+			if e.cursor.processed(evt.blockNumber(), evt.logIndex()) {
+				continue
+			}
+			// Logs mined before the filter was installed
+			// have been fetched with "eth_getLogs" already
+			if e.strategy == EthFilter && !evt.Removed && evt.blockNumber() <= e.filter.fetched {
 				continue
 			}
-			events = append(events, event)
+			handled = append(handled, e.handleLog(evt)...)
 
 			// Check if we can update the "fromBlock" in the query,
 			// so we only get new events from blocks we haven't queried yet
@@ -232,11 +636,622 @@ func (e EthManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
 				e.fq.FromBlock = hexutil.EncodeBig(curBlkn)
 			}
 		}
+		events = append(events, e.logsToEvents(handled)...)
+
+		if e.confirmations > 0 {
+			head, err := e.getBlockNumber()
+			if err != nil {
+				log.Println("failed getting block number:", err)
+				return events, true
+			}
+			events = append(events, e.logsToEvents(e.releaseConfirmed(head))...)
+		}
+
+		// Every block before "fromBlock" has been fully queried
+		if fromBlock, err := hexutil.DecodeUint64(e.fq.FromBlock); err == nil && fromBlock > 0 {
+			e.saveProgress(fromBlock - 1)
+		}
 	}
 
 	return events, true
 }
 
+// fetchHeadLogs fetches the logs of the new head by its block hash,
+// and returns the logs that should be forwarded right away. If blocks
+// were skipped since the last head, their logs are fetched as well. The
+// head is only recorded once every log has been fetched, so that blocks
+// whose logs could not be fetched are fetched again with the next head.
+//
+// Reorgs are not announced by "newHeads", so logs of reorged blocks
+// are only dropped when confirmations are required.
+func (e EthManager) fetchHeadLogs(number uint64, hash common.Hash) ([]ethLogResponse, error) {
+	var logs []ethLogResponse
+	if last := e.heads.last; last > 0 && number > last+1 {
+		log.Printf("Fetching logs for skipped blocks %d to %d\n", last+1, number-1)
+		err := e.getLogsInChunks(last+1, number-1, func(chunk []ethLogResponse, _ uint64) {
+			logs = append(logs, chunk...)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blockLogs, err := e.getBlockLogs(hash)
+	if err != nil {
+		return nil, err
+	}
+	logs = append(logs, blockLogs...)
+	e.heads.last = number
+
+	released := e.processing(number)
+	for _, evt := range logs {
+		if e.backfilled[evt.id()] {
+			continue
+		}
+		released = append(released, e.handleLog(evt)...)
+	}
+	return released, nil
+}
+
+// newFilterArg returns the filter query
+// for installing a filter on the node.
+func (e EthManager) newFilterArg() interface{} {
+	fq := *e.fq
+	fq.FromBlock = "latest"
+	fq.ToBlock = "latest"
+	filter, _ := fq.toMapInterface()
+	return filter
+}
+
+// reinstallFilter installs a new filter on the node in place of
+// one that was dropped, and returns the events emitted meanwhile.
+func (e EthManager) reinstallFilter() []subscriber.Event {
+	e.filter.id = ""
+
+	var id string
+	if err := callJsonRpc(e.caller, "eth_newFilter", []interface{}{e.newFilterArg()}, &id); err != nil {
+		log.Println("failed installing filter:", err)
+		return nil
+	}
+
+	return e.filterInstalled(id)
+}
+
+// filterInstalled stores the ID of an installed filter. The filter only
+// returns logs mined after it was installed, so logs from "fromBlock" up
+// to the current block are fetched with "eth_getLogs". If that fails, the
+// filter is installed again on the next poll.
+func (e EthManager) filterInstalled(id string) []subscriber.Event {
+	events, err := e.catchUp()
+	if err != nil {
+		log.Println("failed catching up on logs:", err)
+		return events
+	}
+
+	e.filter.id = id
+	if fromBlock, err := hexutil.DecodeUint64(e.fq.FromBlock); err == nil && fromBlock > 0 {
+		e.filter.fetched = fromBlock - 1
+	}
+	return events
+}
+
+// catchUp fetches the logs from "fromBlock" up to the current block
+// in chunks, for when the node rejected querying the range at once.
+// "fromBlock" is only moved past chunks that were fully fetched, and
+// an error is returned if the current block was not reached.
+func (e EthManager) catchUp() ([]subscriber.Event, error) {
+	fromBlock, err := hexutil.DecodeUint64(e.fq.FromBlock)
+	if err != nil {
+		return nil, fmt.Errorf("cannot catch up from block %s", e.fq.FromBlock)
+	}
+
+	head, err := e.getBlockNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	var released []ethLogResponse
+	err = e.getLogsInChunks(fromBlock, head, func(logs []ethLogResponse, toBlock uint64) {
+		released = append(released, e.processing(toBlock)...)
+		for _, evt := range logs {
+			if e.cursor.processed(evt.blockNumber(), evt.logIndex()) {
+				continue
+			}
+			released = append(released, e.handleLog(evt)...)
+		}
+		e.fq.FromBlock = hexutil.EncodeUint64(toBlock + 1)
+		e.saveProgress(toBlock)
+	})
+
+	if e.confirmations > 0 {
+		released = append(released, e.releaseConfirmed(head)...)
+		if err == nil {
+			e.saveProgress(head)
+		}
+	}
+
+	return e.logsToEvents(released), err
+}
+
+// saveProgress advances the block cursor to the block provided, or to
+// the block before the first log still waiting for confirmations, waiting
+// to be converted again, or of a new child waiting to be fetched.
+func (e EthManager) saveProgress(blockNumber uint64) {
+	var held []uint64
+	if e.confirmations > 0 && len(e.queue.logs) > 0 {
+		held = append(held, e.queue.logs[0].blockNumber())
+	}
+	if first, ok := e.retry.first(); ok {
+		held = append(held, first)
+	}
+	if first, ok := e.factory.firstPending(); ok {
+		held = append(held, first)
+	}
+
+	for _, first := range held {
+		if first == 0 {
+			return
+		}
+		if first <= blockNumber {
+			blockNumber = first - 1
+		}
+	}
+	e.cursor.advance(blockNumber, 0)
+}
+
+// ethLogEvent is the payload sent to the Chainlink node for a log.
+type ethLogEvent struct {
+	ethLogResponse
+	Decoded     map[string]interface{} `json:"decoded,omitempty"`
+	Transaction *ethTxDetails          `json:"transaction,omitempty"`
+	Block       *ethBlockDetails       `json:"block,omitempty"`
+}
+
+// logsToEvents converts logs to events. If the subscription has an
+// event ABI, the decoded log is added to the event. OracleRequest logs
+// of EthRunLog subscriptions are converted to flat job params instead.
+//
+// If the subscription filters on transactions, logs whose transaction
+// does not pass the filter are dropped, and the transaction details are
+// added to the event. Removed logs are forwarded without the details,
+// as their transaction may no longer be available. The same goes for the
+// block header, which is added if the subscription asks for it.
+//
+// Logs whose transaction or block cannot be fetched are queued, and converted
+// again together with the next logs, so that a failing call does not
+// lose them.
+func (e EthManager) logsToEvents(logs []ethLogResponse) []subscriber.Event {
+	logs = append(e.retry.take(logs), logs...)
+
+	var events []subscriber.Event
+	txs := txDetailsCache{}
+	for _, evt := range logs {
+		payload := ethLogEvent{ethLogResponse: evt}
+		if e.txFilter != nil && !evt.Removed {
+			tx, err := txs.get(e, evt.TransactionHash)
+			if err != nil {
+				log.Println("Failed fetching transaction:", err)
+				e.retry.add(evt)
+				continue
+			}
+			if !e.txFilter.matches(tx) {
+				continue
+			}
+			payload.Transaction = &tx
+		}
+		if e.blocks != nil && !evt.Removed {
+			block, err := e.blocks.get(e, evt)
+			if err != nil {
+				log.Println("Failed fetching block:", err)
+				e.retry.add(evt)
+				continue
+			}
+			payload.Block = &block
+		}
+
+		event, err := e.logToEvent(payload)
+		if err != nil {
+			log.Println("Failed converting log:", err)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func (e EthManager) logToEvent(payload ethLogEvent) (subscriber.Event, error) {
+	evt := payload.ethLogResponse
+	if e.kind == EthRunLog {
+		// A request that is already being
+		// fulfilled cannot be taken back.
+		if evt.Removed {
+			return nil, fmt.Errorf("ignoring removed OracleRequest in tx %s", evt.TransactionHash)
+		}
+
+		params, err := decodeRunLog(evt, e.specIDs)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(params)
+	}
+
+	if e.transfer != nil && !e.transfer.matches(evt) {
+		return nil, nil
+	}
+
+	if e.event != nil {
+		decoded, err := decodeLog(e.event, evt)
+		if err != nil {
+			log.Println("Failed decoding log:", err)
+		}
+		payload.Decoded = decoded
+	}
+
+	if e.deviation != nil {
+		// A value that has been forwarded cannot be taken
+		// back, and the next value is compared to it.
+		if evt.Removed {
+			return nil, nil
+		}
+		value, err := integerValue(payload.Decoded, e.deviation.field)
+		if err != nil {
+			return nil, err
+		}
+		if !e.deviation.check(value) {
+			return nil, nil
+		}
+	}
+
+	return json.Marshal(payload)
+}
+
+// handleLog returns the logs that should be forwarded right away
+// after receiving evt. If confirmations are required, evt is held
+// in the confirmation queue instead.
+func (e EthManager) handleLog(evt ethLogResponse) []ethLogResponse {
+	if e.factory != nil {
+		if e.factory.isCreation(evt) {
+			return e.handleFactoryLog(evt)
+		}
+		if !e.factory.matches(evt) {
+			return nil
+		}
+	}
+
+	if evt.Removed {
+		// A log that is still waiting for confirmations
+		// was never forwarded, so it can simply be dropped.
+		if e.confirmations > 0 && e.queue.remove(evt) {
+			return nil
+		}
+		return []ethLogResponse{evt}
+	}
+
+	if e.confirmations == 0 {
+		return []ethLogResponse{evt}
+	}
+
+	e.queue.add(evt)
+	return nil
+}
+
+// releaseConfirmed returns the held logs that have reached the required
+// number of confirmations at the head provided. Before a block's logs are
+// released, its hash is compared to the canonical chain, and logs from
+// blocks that have been reorged out are dropped. When using RPC, the
+// logs are then queried again from the reorged block.
+func (e EthManager) releaseConfirmed(head uint64) []ethLogResponse {
+	var released []ethLogResponse
+
+	for len(e.queue.logs) > 0 {
+		blockNumber := e.queue.logs[0].blockNumber()
+		if blockNumber+e.confirmations > head {
+			break
+		}
+
+		hash, err := e.getBlockHash(blockNumber)
+		if err != nil {
+			// Keep the logs queued, and retry on the next head
+			log.Println("failed getting block hash:", err)
+			break
+		}
+
+		reorged := false
+		for _, evt := range e.queue.popBlock(blockNumber) {
+			if common.HexToHash(evt.BlockHash) != hash {
+				log.Printf("Dropping log %s in tx %s, block %d was reorged\n", evt.LogIndex, evt.TransactionHash, blockNumber)
+				reorged = true
+				continue
+			}
+			released = append(released, evt)
+		}
+
+		// The logs of the following blocks are queried
+		// again, and released once their blocks are checked
+		if reorged && e.rewind(blockNumber) {
+			break
+		}
+	}
+
+	return released
+}
+
+// rewind moves "fromBlock" back to the reorged block provided, so that
+// the logs of the block that replaced it are queried. Returns false if
+// logs are not queried by block range.
+func (e EthManager) rewind(blockNumber uint64) bool {
+	if e.p != subscriber.RPC {
+		return false
+	}
+	if fromBlock, err := hexutil.DecodeUint64(e.fq.FromBlock); err == nil && fromBlock > blockNumber {
+		log.Printf("Querying logs again from reorged block %d\n", blockNumber)
+		e.fq.FromBlock = hexutil.EncodeUint64(blockNumber)
+	}
+	return true
+}
+
+func (e EthManager) getBlockNumber() (uint64, error) {
+	var res string
+	if err := callJsonRpc(e.caller, "eth_blockNumber", nil, &res); err != nil {
+		return 0, err
+	}
+	return hexutil.DecodeUint64(res)
+}
+
+// getLogs fetches the logs matching the filter query
+// between the blocks provided, inclusive.
+func (e EthManager) getLogs(fromBlock, toBlock uint64) ([]ethLogResponse, error) {
+	fq := *e.fq
+	fq.FromBlock = hexutil.EncodeUint64(fromBlock)
+	fq.ToBlock = hexutil.EncodeUint64(toBlock)
+
+	filter, err := fq.toMapInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []ethLogResponse
+	err = callJsonRpc(e.caller, "eth_getLogs", []interface{}{filter}, &logs)
+	return logs, err
+}
+
+// getLogsInChunks fetches the logs between the blocks provided, inclusive,
+// in chunks of blocks. handle is called with the logs of each chunk in order,
+// once the chunk has been fully fetched. The chunk size is halved when the
+// node rejects a query for covering too much, and grown again on success.
+func (e EthManager) getLogsInChunks(fromBlock, toBlock uint64, handle func(logs []ethLogResponse, toBlock uint64)) error {
+	for fromBlock <= toBlock {
+		end := toBlock
+		if end-fromBlock >= e.logRange.size {
+			end = fromBlock + e.logRange.size - 1
+		}
+
+		logs, err := e.getLogs(fromBlock, end)
+		if err != nil {
+			if !isLogRangeError(err) || !e.logRange.shrink() {
+				return err
+			}
+			continue
+		}
+
+		handle(logs, end)
+		e.logRange.grow()
+		fromBlock = end + 1
+	}
+
+	return nil
+}
+
+// getBlockLogs fetches the logs matching the
+// filter query in the block with the hash provided.
+func (e EthManager) getBlockLogs(blockHash common.Hash) ([]ethLogResponse, error) {
+	fq := *e.fq
+	fq.BlockHash = &blockHash
+	fq.FromBlock = ""
+	fq.ToBlock = ""
+
+	filter, err := fq.toMapInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []ethLogResponse
+	err = callJsonRpc(e.caller, "eth_getLogs", []interface{}{filter}, &logs)
+	return logs, err
+}
+
+func (e EthManager) getBlockHash(number uint64) (common.Hash, error) {
+	var block *ethHeadResponse
+	err := callJsonRpc(e.caller, "eth_getBlockByNumber", []interface{}{hexutil.EncodeUint64(number), false}, &block)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if block == nil {
+		return common.Hash{}, fmt.Errorf("block %d not found", number)
+	}
+	return common.HexToHash(block.Hash), nil
+}
+
+func (evt ethLogResponse) blockNumber() uint64 {
+	n, _ := hexutil.DecodeUint64(evt.BlockNumber)
+	return n
+}
+
+func (evt ethLogResponse) logIndex() uint64 {
+	n, _ := hexutil.DecodeUint64(evt.LogIndex)
+	return n
+}
+
+// id uniquely identifies the log in the chain
+func (evt ethLogResponse) id() string {
+	return evt.BlockHash + "/" + evt.LogIndex
+}
+
+// maxLogRange is the largest number of
+// blocks queried by a single "eth_getLogs".
+const maxLogRange = 5000
+
+// logRange holds the number of blocks to query logs for at once.
+type logRange struct {
+	size uint64
+}
+
+// shrink halves the size, and returns false
+// if it cannot be made any smaller.
+func (r *logRange) shrink() bool {
+	if r.size <= 1 {
+		return false
+	}
+	r.size /= 2
+	return true
+}
+
+func (r *logRange) grow() {
+	r.size *= 2
+	if r.size > maxLogRange {
+		r.size = maxLogRange
+	}
+}
+
+// isLogRangeError returns true if the error is returned by a node,
+// or a hosted provider, rejecting a log query that covers too many
+// blocks or returns too many results. Other errors, e.g. rate limits
+// or timeouts, are not solved by querying smaller ranges.
+func isLogRangeError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"query returned more than", "block range", "log response size exceeded", "-32005"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// headTracker holds the number of the
+// last block logs have been fetched for.
+type headTracker struct {
+	last uint64
+}
+
+// logFilter holds the filter installed on the node with "eth_newFilter".
+type logFilter struct {
+	id string
+	// fetched is the last block logs were fetched for
+	// with "eth_getLogs" when the filter was installed.
+	fetched uint64
+}
+
+// isFilterNotFoundError returns true if the error is returned by
+// a node that does not know the filter, e.g. after a restart.
+func isFilterNotFoundError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "filter not found")
+}
+
+// confirmationQueue holds logs waiting for confirmations,
+// ordered by block number and log index.
+type confirmationQueue struct {
+	logs []ethLogResponse
+}
+
+func (q *confirmationQueue) add(evt ethLogResponse) {
+	// Logs are queried again after a reorg
+	for _, l := range q.logs {
+		if l.BlockHash == evt.BlockHash && l.TransactionHash == evt.TransactionHash && l.LogIndex == evt.LogIndex {
+			return
+		}
+	}
+
+	i := sort.Search(len(q.logs), func(i int) bool {
+		if q.logs[i].blockNumber() != evt.blockNumber() {
+			return q.logs[i].blockNumber() > evt.blockNumber()
+		}
+		return q.logs[i].logIndex() > evt.logIndex()
+	})
+	q.logs = append(q.logs, ethLogResponse{})
+	copy(q.logs[i+1:], q.logs[i:])
+	q.logs[i] = evt
+}
+
+// remove drops the queued log matching evt,
+// and returns whether a log was found.
+func (q *confirmationQueue) remove(evt ethLogResponse) bool {
+	for i, l := range q.logs {
+		if l.BlockHash == evt.BlockHash && l.TransactionHash == evt.TransactionHash && l.LogIndex == evt.LogIndex {
+			q.logs = append(q.logs[:i], q.logs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// popBlock removes and returns the queued logs
+// at the front of the queue from the block provided.
+func (q *confirmationQueue) popBlock(number uint64) []ethLogResponse {
+	i := 0
+	for i < len(q.logs) && q.logs[i].blockNumber() == number {
+		i++
+	}
+	logs := q.logs[:i:i]
+	q.logs = q.logs[i:]
+	return logs
+}
+
+// retryQueue holds logs that could not be converted to events, as
+// fetching their details failed. A nil retryQueue is valid, and
+// drops such logs instead.
+type retryQueue struct {
+	logs []ethLogResponse
+}
+
+func (q *retryQueue) add(evt ethLogResponse) {
+	if q == nil {
+		return
+	}
+	q.logs = append(q.logs, evt)
+}
+
+// take removes and returns the queued logs, except the
+// ones removed from the chain by the logs provided.
+func (q *retryQueue) take(logs []ethLogResponse) []ethLogResponse {
+	if q == nil {
+		return nil
+	}
+
+	var taken []ethLogResponse
+	for _, l := range q.logs {
+		removed := false
+		for _, evt := range logs {
+			if evt.Removed && l.BlockHash == evt.BlockHash && l.TransactionHash == evt.TransactionHash && l.LogIndex == evt.LogIndex {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			taken = append(taken, l)
+		}
+	}
+	q.logs = nil
+	return taken
+}
+
+// first returns the lowest block number of the queued
+// logs, and false if there are no queued logs.
+func (q *retryQueue) first() (uint64, bool) {
+	if q == nil || len(q.logs) == 0 {
+		return 0, false
+	}
+	first := q.logs[0].blockNumber()
+	for _, l := range q.logs[1:] {
+		if n := l.blockNumber(); n < first {
+			first = n
+		}
+	}
+	return first, true
+}
+
 type filterQuery struct {
 	BlockHash *common.Hash     // used by eth_getLogs, return logs only from block with this hash
 	FromBlock string           // beginning of the queried range, nil means genesis block
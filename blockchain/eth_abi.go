@@ -0,0 +1,339 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// parseEventABI parses an event from either a JSON ABI fragment, or from
+// a human-readable signature such as "Transfer(address indexed from,
+// address indexed to, uint256 value)". Argument names are optional.
+func parseEventABI(event string) (*abi.Event, error) {
+	event = strings.TrimSpace(event)
+	if strings.HasPrefix(event, "{") {
+		event = "[" + event + "]"
+	}
+	if !strings.HasPrefix(event, "[") {
+		return parseEventSignature(event)
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(event))
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Events) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 event in ABI, got %d", len(parsed.Events))
+	}
+
+	for _, e := range parsed.Events {
+		return &e, nil
+	}
+	return nil, nil
+}
+
+func parseEventSignature(signature string) (*abi.Event, error) {
+	signature = strings.TrimPrefix(signature, "event ")
+	start := strings.Index(signature, "(")
+	if start < 1 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("invalid event signature: %s", signature)
+	}
+
+	name := strings.TrimSpace(signature[:start])
+	inputs, err := parseArguments(signature[start+1:len(signature)-1], true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &abi.Event{
+		Name:    name,
+		RawName: name,
+		Inputs:  inputs,
+	}, nil
+}
+
+// parseFunctionABI parses a function from either a JSON ABI fragment, or
+// from a human-readable signature such as "balanceOf(address owner)
+// returns (uint256)". Argument names and "returns" are optional.
+func parseFunctionABI(function string) (*abi.Method, error) {
+	function = strings.TrimSpace(function)
+	if strings.HasPrefix(function, "{") {
+		function = "[" + function + "]"
+	}
+	if strings.HasPrefix(function, "[") {
+		parsed, err := abi.JSON(strings.NewReader(function))
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed.Methods) != 1 {
+			return nil, fmt.Errorf("expected exactly 1 function in ABI, got %d", len(parsed.Methods))
+		}
+
+		for _, m := range parsed.Methods {
+			return &m, nil
+		}
+	}
+
+	signature := strings.TrimPrefix(function, "function ")
+	start := strings.Index(signature, "(")
+	end := strings.Index(signature, ")")
+	if start < 1 || end < start {
+		return nil, fmt.Errorf("invalid function signature: %s", signature)
+	}
+
+	inputs, err := parseArguments(signature[start+1:end], false)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs abi.Arguments
+	returns := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(signature[end+1:]), "returns"))
+	if returns != "" {
+		if !strings.HasPrefix(returns, "(") || !strings.HasSuffix(returns, ")") {
+			return nil, fmt.Errorf("invalid function signature: %s", signature)
+		}
+		outputs, err = parseArguments(returns[1:len(returns)-1], false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := strings.TrimSpace(signature[:start])
+	return &abi.Method{
+		Name:    name,
+		RawName: name,
+		Const:   true,
+		Inputs:  inputs,
+		Outputs: outputs,
+	}, nil
+}
+
+// parseArguments parses a comma separated list of arguments in a
+// human-readable signature, e.g. "address indexed from, uint256".
+// Data locations such as "memory" are ignored.
+func parseArguments(params string, allowIndexed bool) (abi.Arguments, error) {
+	if strings.ContainsAny(params, "()") {
+		return nil, fmt.Errorf("tuples are not supported in signatures: %s", params)
+	}
+	if strings.TrimSpace(params) == "" {
+		return nil, nil
+	}
+
+	var args abi.Arguments
+	for _, param := range strings.Split(params, ",") {
+		var fields []string
+		for _, field := range strings.Fields(param) {
+			if field != "memory" && field != "calldata" && field != "storage" {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) < 1 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid argument: %s", param)
+		}
+
+		typ, err := abi.NewType(fields[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		arg := abi.Argument{Type: typ}
+
+		for _, field := range fields[1:] {
+			if field == "indexed" {
+				if !allowIndexed || arg.Indexed || arg.Name != "" {
+					return nil, fmt.Errorf("invalid argument: %s", param)
+				}
+				arg.Indexed = true
+			} else if arg.Name == "" {
+				arg.Name = field
+			} else {
+				return nil, fmt.Errorf("invalid argument: %s", param)
+			}
+		}
+
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// decodeLog decodes the indexed topics and the data of a log emitted by
+// the event into a map of named fields. Unnamed arguments are named by
+// their position, e.g. "arg0". Indexed arguments of dynamic types are only
+// stored as a hash in the topics, so the hash is returned instead.
+func decodeLog(event *abi.Event, evt ethLogResponse) (map[string]interface{}, error) {
+	data, err := hexutil.Decode(evt.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := event.Inputs.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := evt.Topics
+	if !event.Anonymous {
+		if len(topics) < 1 {
+			return nil, fmt.Errorf("missing event signature in topics")
+		}
+		topics = topics[1:]
+	}
+
+	decoded := make(map[string]interface{})
+	for i, input := range event.Inputs {
+		name := argumentName(input, i)
+
+		if !input.Indexed {
+			decoded[name] = abiValueToJson(input.Type, values[0])
+			values = values[1:]
+			continue
+		}
+
+		if len(topics) < 1 {
+			return nil, fmt.Errorf("missing topic for indexed argument %s", name)
+		}
+		topic := common.HexToHash(topics[0])
+		topics = topics[1:]
+
+		switch input.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+			decoded[name] = topic.Hex()
+		default:
+			value, err := abi.Arguments{{Type: input.Type}}.UnpackValues(topic.Bytes())
+			if err != nil {
+				return nil, err
+			}
+			decoded[name] = abiValueToJson(input.Type, value[0])
+		}
+	}
+
+	return decoded, nil
+}
+
+// decodeValues decodes the ABI encoded values of args into a map of
+// named fields. Unnamed arguments are named by their position.
+func decodeValues(args abi.Arguments, data []byte) (map[string]interface{}, error) {
+	values, err := args.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make(map[string]interface{})
+	for i, arg := range args {
+		decoded[argumentName(arg, i)] = abiValueToJson(arg.Type, values[i])
+	}
+	return decoded, nil
+}
+
+func argumentName(arg abi.Argument, i int) string {
+	if arg.Name == "" {
+		return fmt.Sprintf("arg%d", i)
+	}
+	return arg.Name
+}
+
+// integerFits returns true if n is in the range of the integer type typ,
+// from 0 to 2^size-1 if unsigned, or from -2^(size-1) to 2^(size-1)-1.
+func integerFits(typ abi.Type, n *big.Int) bool {
+	if typ.T == abi.UintTy {
+		return n.Sign() >= 0 && n.BitLen() <= typ.Size
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(typ.Size-1))
+	if n.Sign() < 0 {
+		return n.Cmp(new(big.Int).Neg(limit)) >= 0
+	}
+	return n.Cmp(limit) < 0
+}
+
+// parseAbiValue converts a string to a value of type typ
+// that can be packed by the abi package. Integers can be
+// decimal or hex, and bytes are hex. Arrays, slices and
+// tuples are not supported.
+func parseAbiValue(typ abi.Type, value string) (interface{}, error) {
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || !integerFits(typ, n) {
+			return nil, fmt.Errorf("invalid %s: %s", typ.String(), value)
+		}
+		if typ.Type.Kind() == reflect.Ptr {
+			return n, nil
+		}
+		if typ.T == abi.IntTy {
+			return reflect.ValueOf(n.Int64()).Convert(typ.Type).Interface(), nil
+		}
+		return reflect.ValueOf(n.Uint64()).Convert(typ.Type).Interface(), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("invalid address: %s", value)
+		}
+		return common.HexToAddress(value), nil
+	case abi.BytesTy:
+		return hexutil.Decode(value)
+	case abi.FixedBytesTy:
+		bz, err := hexutil.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(bz) > typ.Size {
+			return nil, fmt.Errorf("value too long for %s: %s", typ.String(), value)
+		}
+		array := reflect.New(typ.Type).Elem()
+		reflect.Copy(array, reflect.ValueOf(bz))
+		return array.Interface(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported argument type: %s", typ.String())
+}
+
+// abiValueToJson converts a value of type typ unpacked by the abi package
+// into a value with a readable JSON representation. Integers are converted
+// to decimal strings to avoid losing precision, and bytes are converted to hex.
+func abiValueToJson(typ abi.Type, value interface{}) interface{} {
+	rv := reflect.ValueOf(value)
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		return fmt.Sprint(value)
+	case abi.AddressTy:
+		return value.(common.Address).Hex()
+	case abi.HashTy, abi.FixedBytesTy, abi.BytesTy:
+		bz := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(bz), rv)
+		return hexutil.Encode(bz)
+	case abi.ArrayTy, abi.SliceTy:
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = abiValueToJson(*typ.Elem, rv.Index(i).Interface())
+		}
+		return values
+	case abi.TupleTy:
+		values := make(map[string]interface{})
+		for i, elem := range typ.TupleElems {
+			values[typ.TupleRawNames[i]] = abiValueToJson(*elem, rv.Field(i).Interface())
+		}
+		return values
+	}
+
+	return value
+}
+
+// rawParamToString returns the string in raw if it is a JSON
+// string, or the raw JSON otherwise.
+func rawParamToString(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	return string(raw)
+}
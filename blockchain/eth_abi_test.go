@@ -0,0 +1,291 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+const transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+func Test_parseEventABI(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		wantSig string
+		wantErr bool
+	}{
+		{
+			"signature without names",
+			"Transfer(address indexed,address indexed,uint256)",
+			"Transfer(address,address,uint256)",
+			false,
+		},
+		{
+			"signature with names",
+			"event Transfer(address indexed from, address indexed to, uint256 value)",
+			"Transfer(address,address,uint256)",
+			false,
+		},
+		{
+			"signature without arguments",
+			"Ping()",
+			"Ping()",
+			false,
+		},
+		{
+			"signature with arrays",
+			"Values(uint8[2] indexed a, bytes32[] b)",
+			"Values(uint8[2],bytes32[])",
+			false,
+		},
+		{
+			"ABI fragment",
+			`{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}`,
+			"Transfer(address,address,uint256)",
+			false,
+		},
+		{
+			"ABI array",
+			`[{"type":"function","name":"foo","inputs":[],"outputs":[]},{"type":"event","name":"Ping","inputs":[]}]`,
+			"Ping()",
+			false,
+		},
+		{"missing parenthesis", "Transfer(address", "", true},
+		{"missing name", "(address)", "", true},
+		{"unknown type", "Transfer(foo)", "", true},
+		{"too many fields", "Transfer(address indexed from to)", "", true},
+		{"tuple", "Transfer((address,uint256))", "", true},
+		{"ABI without events", `[{"type":"function","name":"foo","inputs":[],"outputs":[]}]`, "", true},
+		{"invalid ABI", `{"type":"event","inputs":[{"type":"foo"}]}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := parseEventABI(tt.event)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSig, event.Sig())
+		})
+	}
+
+	t.Run("hashes signature", func(t *testing.T) {
+		event, err := parseEventABI("Transfer(address indexed,address indexed,uint256)")
+		require.NoError(t, err)
+		assert.Equal(t, transferTopic, event.ID().Hex())
+	})
+}
+
+func Test_decodeLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		log     ethLogResponse
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			"transfer",
+			"Transfer(address indexed from,address indexed to,uint256 value)",
+			ethLogResponse{
+				Topics: []string{
+					transferTopic,
+					"0x000000000000000000000000049bd8c3adc3fe7d3fc2a44541d955a537c2a484",
+					"0x0000000000000000000000000000000000000000000000000000000000000abc",
+				},
+				Data: "0x00000000000000000000000000000000000000000000000000000000000003e8",
+			},
+			map[string]interface{}{
+				"from":  "0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484",
+				"to":    "0x0000000000000000000000000000000000000aBc",
+				"value": "1000",
+			},
+			false,
+		},
+		{
+			"unnamed and dynamic arguments",
+			"Foo(string indexed,bool indexed,bytes,uint8[2])",
+			ethLogResponse{
+				Topics: []string{
+					"0x0000000000000000000000000000000000000000000000000000000000000001",
+					"0x1111111111111111111111111111111111111111111111111111111111111111",
+					"0x0000000000000000000000000000000000000000000000000000000000000001",
+				},
+				Data: "0x" +
+					"0000000000000000000000000000000000000000000000000000000000000060" +
+					"0000000000000000000000000000000000000000000000000000000000000001" +
+					"0000000000000000000000000000000000000000000000000000000000000002" +
+					"0000000000000000000000000000000000000000000000000000000000000002" +
+					"abcd000000000000000000000000000000000000000000000000000000000000",
+			},
+			map[string]interface{}{
+				"arg0": "0x1111111111111111111111111111111111111111111111111111111111111111",
+				"arg1": true,
+				"arg2": "0xabcd",
+				"arg3": []interface{}{"1", "2"},
+			},
+			false,
+		},
+		{
+			"anonymous",
+			`{"type":"event","name":"Foo","anonymous":true,"inputs":[{"name":"id","type":"bytes32","indexed":true}]}`,
+			ethLogResponse{
+				Topics: []string{"0x0000000000000000000000000000000000000000000000000000000000000abc"},
+				Data:   "0x",
+			},
+			map[string]interface{}{
+				"id": "0x0000000000000000000000000000000000000000000000000000000000000abc",
+			},
+			false,
+		},
+		{
+			"missing topic",
+			"Transfer(address indexed,address indexed,uint256)",
+			ethLogResponse{
+				Topics: []string{transferTopic},
+				Data:   "0x00000000000000000000000000000000000000000000000000000000000003e8",
+			},
+			nil,
+			true,
+		},
+		{
+			"short data",
+			"Transfer(address indexed,address indexed,uint256)",
+			ethLogResponse{Topics: []string{transferTopic}, Data: "0x"},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := parseEventABI(tt.event)
+			require.NoError(t, err)
+
+			got, err := decodeLog(event, tt.log)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEthManager_ParseResponse_Decoded(t *testing.T) {
+	event, err := parseEventABI("Transfer(address indexed from,address indexed to,uint256 value)")
+	require.NoError(t, err)
+	e := EthManager{fq: &filterQuery{}, p: subscriber.WS, queue: &confirmationQueue{}, backfilled: map[string]bool{}, event: event}
+
+	t.Run("adds decoded fields", func(t *testing.T) {
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"blockNumber":"0x1","logIndex":"0x0","topics":["` + transferTopic + `","0x0000000000000000000000000000000000000000000000000000000000000001","0x0000000000000000000000000000000000000000000000000000000000000002"],"data":"0x0000000000000000000000000000000000000000000000000000000000000003"}}}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+
+		var got ethLogEvent
+		require.NoError(t, json.Unmarshal(events[0], &got))
+		assert.Equal(t, "0x1", got.BlockNumber)
+		assert.Equal(t, map[string]interface{}{
+			"from":  "0x0000000000000000000000000000000000000001",
+			"to":    "0x0000000000000000000000000000000000000002",
+			"value": "3",
+		}, got.Decoded)
+	})
+
+	t.Run("forwards undecodable logs", func(t *testing.T) {
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"blockNumber":"0x1","logIndex":"0x1","topics":["` + transferTopic + `"],"data":"0x"}}}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		assert.NotContains(t, string(events[0]), "decoded")
+	})
+}
+
+func Test_parseFunctionABI(t *testing.T) {
+	tests := []struct {
+		name        string
+		function    string
+		wantSig     string
+		wantOutputs int
+		wantErr     bool
+	}{
+		{"without returns", "latestAnswer()", "latestAnswer()", 0, false},
+		{"with returns", "function balanceOf(address owner) returns (uint256)", "balanceOf(address)", 1, false},
+		{"with short returns", "getRoundData(uint80)(uint80,int256,uint256,uint256,uint80)", "getRoundData(uint80)", 5, false},
+		{"with data location", "greet(string memory name) returns (string memory)", "greet(string)", 1, false},
+		{
+			"ABI fragment",
+			`{"type":"function","name":"latestAnswer","constant":true,"inputs":[],"outputs":[{"name":"","type":"int256"}]}`,
+			"latestAnswer()",
+			1,
+			false,
+		},
+		{"indexed argument", "foo(address indexed)", "", 0, true},
+		{"invalid returns", "foo() returns uint256", "", 0, true},
+		{"missing parenthesis", "foo", "", 0, true},
+		{"ABI without functions", `[{"type":"event","name":"Ping","inputs":[]}]`, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, err := parseFunctionABI(tt.function)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSig, method.Sig())
+			assert.Len(t, method.Outputs, tt.wantOutputs)
+		})
+	}
+}
+
+func Test_parseAbiValue(t *testing.T) {
+	tests := []struct {
+		typ     string
+		value   string
+		want    interface{}
+		wantErr bool
+	}{
+		{"uint256", "1000", big.NewInt(1000), false},
+		{"int256", "-0x10", big.NewInt(-16), false},
+		{"uint8", "255", uint8(255), false},
+		{"uint8", "256", nil, true},
+		{"uint64", "-1", nil, true},
+		{"int32", "-5", int32(-5), false},
+		{"int8", "127", int8(127), false},
+		{"int8", "-128", int8(-128), false},
+		{"int8", "128", nil, true},
+		{"int8", "200", nil, true},
+		{"int8", "-129", nil, true},
+		{"int256", "-0x8000000000000000000000000000000000000000000000000000000000000000", new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255)), false},
+		{"int256", "0x8000000000000000000000000000000000000000000000000000000000000000", nil, true},
+		{"bool", "true", true, false},
+		{"string", "abc", "abc", false},
+		{"address", "0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484", common.HexToAddress("0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484"), false},
+		{"address", "0x123", nil, true},
+		{"bytes", "0xabcd", []byte{0xab, 0xcd}, false},
+		{"bytes4", "0x1234", [4]byte{0x12, 0x34}, false},
+		{"bytes2", "0x123456", nil, true},
+		{"uint256[]", "1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ+" "+tt.value, func(t *testing.T) {
+			typ, err := abi.NewType(tt.typ, nil)
+			require.NoError(t, err)
+
+			got, err := parseAbiValue(typ, tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
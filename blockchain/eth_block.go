@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+// maxCachedBlocks is the number of block headers kept by blockCache.
+// Logs are forwarded roughly in block order, so only the most
+// recent blocks need to be kept.
+const maxCachedBlocks = 128
+
+// ethBlockDetails are the fields of the block header of a
+// log that are attached to the event sent to the Chainlink node.
+// BaseFeePerGas is empty for blocks from before the London fork.
+type ethBlockDetails struct {
+	Timestamp     string `json:"timestamp"`
+	BaseFeePerGas string `json:"baseFeePerGas,omitempty"`
+	Miner         string `json:"miner"`
+}
+
+// blockCache caches block headers by block hash, so that the header
+// is only fetched once for all the logs in a block. A nil blockCache
+// means that block headers are not fetched.
+type blockCache struct {
+	blocks map[string]ethBlockDetails
+	// order is the block hashes in the order they were cached
+	order []string
+}
+
+func newBlockCache() *blockCache {
+	return &blockCache{blocks: make(map[string]ethBlockDetails)}
+}
+
+// get returns the header of the block the log is in, fetching it if needed.
+// Blocks are fetched by hash, so that a reorg cannot mix up headers.
+func (c *blockCache) get(e EthManager, evt ethLogResponse) (ethBlockDetails, error) {
+	if block, ok := c.blocks[evt.BlockHash]; ok {
+		return block, nil
+	}
+
+	var block *ethBlockDetails
+	var err error
+	if evt.BlockHash != "" {
+		err = callJsonRpc(e.caller, "eth_getBlockByHash", []interface{}{evt.BlockHash, false}, &block)
+	} else {
+		err = callJsonRpc(e.caller, "eth_getBlockByNumber", []interface{}{evt.BlockNumber, false}, &block)
+	}
+	if err != nil {
+		return ethBlockDetails{}, err
+	}
+	if block == nil {
+		return ethBlockDetails{}, fmt.Errorf("block %s of log %s not found", evt.BlockNumber, evt.LogIndex)
+	}
+
+	if evt.BlockHash != "" {
+		c.add(evt.BlockHash, *block)
+	}
+	return *block, nil
+}
+
+func (c *blockCache) add(hash string, block ethBlockDetails) {
+	if len(c.order) >= maxCachedBlocks {
+		delete(c.blocks, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.blocks[hash] = block
+	c.order = append(c.order, hash)
+}
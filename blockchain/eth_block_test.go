@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestEthManager_BlockHeaders(t *testing.T) {
+	calls := map[string]int{}
+	missing := "0x03"
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		block := params[0].(string)
+		calls[msg.Method+" "+block]++
+
+		switch msg.Method {
+		case "eth_getBlockByHash", "eth_getBlockByNumber":
+			if block == missing {
+				return nil, nil
+			}
+			return map[string]string{"number": "0x1", "timestamp": "0x5e8a1b2c", "baseFeePerGas": "0x7", "miner": "0x0000000000000000000000000000000000000001"}, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{BlockHeaders: true}}, nil)
+	require.NoError(t, err)
+	e.caller = caller
+
+	logs := func(logs ...string) []byte {
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":[%s]}`, strings.Join(logs, ",")))
+	}
+	logAt := func(blockHash, logIndex string) string {
+		return fmt.Sprintf(`{"blockNumber":"0x1","blockHash":"%s","logIndex":"%s"}`, blockHash, logIndex)
+	}
+
+	events, ok := e.ParseResponse(logs(logAt("0x01", "0x0"), logAt("0x01", "0x1"), logAt("0x03", "0x2")))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x1/0x0", "0x1/0x1"}, logPositions(t, events))
+
+	var got ethLogEvent
+	require.NoError(t, json.Unmarshal(events[1], &got))
+	assert.Equal(t, &ethBlockDetails{Timestamp: "0x5e8a1b2c", BaseFeePerGas: "0x7", Miner: "0x0000000000000000000000000000000000000001"}, got.Block)
+
+	// Headers are cached across responses
+	_, ok = e.ParseResponse(logs(logAt("0x01", "0x3")))
+	require.True(t, ok)
+	assert.Equal(t, 1, calls["eth_getBlockByHash 0x01"])
+
+	t.Run("fetches by number without a block hash", func(t *testing.T) {
+		events, ok := e.ParseResponse(logs(`{"blockNumber":"0x2","logIndex":"0x0"}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		assert.Equal(t, 1, calls["eth_getBlockByNumber 0x2"])
+	})
+
+	t.Run("retries logs whose block cannot be fetched", func(t *testing.T) {
+		missing = ""
+		events, ok := e.ParseResponse(logs())
+		require.True(t, ok)
+		assert.Equal(t, []string{"0x1/0x2"}, logPositions(t, events))
+	})
+}
+
+func Test_blockCache_add(t *testing.T) {
+	c := newBlockCache()
+	for i := 0; i < maxCachedBlocks+1; i++ {
+		c.add(fmt.Sprintf("0x%x", i), ethBlockDetails{})
+	}
+
+	assert.Len(t, c.blocks, maxCachedBlocks)
+	assert.NotContains(t, c.blocks, "0x0")
+	assert.Contains(t, c.blocks, fmt.Sprintf("0x%x", maxCachedBlocks))
+}
@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+)
+
+// EthBloom is the strategy of Ethereum subscriptions that poll block
+// headers, and only fetch the logs of blocks whose logsBloom may
+// contain logs matching the subscription.
+const EthBloom = "bloom"
+
+// maxBloomBlocks is the maximum number of block
+// headers fetched by the EthBloom strategy per poll.
+const maxBloomBlocks = 100
+
+const bloomLength = 256
+
+// bloomStats counts the blocks checked by the EthBloom strategy,
+// and the blocks skipped without calling "eth_getLogs".
+type bloomStats struct {
+	checked uint64
+	skipped uint64
+}
+
+// ethBlockBloom is a block header with its logsBloom.
+type ethBlockBloom struct {
+	Number    string        `json:"number"`
+	Hash      string        `json:"hash"`
+	LogsBloom hexutil.Bytes `json:"logsBloom"`
+}
+
+// bloomContains returns true if the value may be in the bloom filter.
+// Each value sets 3 bits of the 2048 bit filter, taken from the first
+// 6 bytes of its hash.
+func bloomContains(bloom []byte, value []byte) bool {
+	if len(bloom) != bloomLength {
+		// Cannot tell, so the block has to be fetched
+		return true
+	}
+
+	hash := crypto.Keccak256(value)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(hash[i])<<8 | uint(hash[i+1])) & 2047
+		if bloom[bloomLength-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomMatches returns true if the bloom filter may contain logs matching
+// the filter query: a log from one of its addresses, and one of its topics
+// for every topic position.
+func bloomMatches(bloom []byte, fq *filterQuery) bool {
+	if len(fq.Addresses) > 0 {
+		found := false
+		for _, a := range fq.Addresses {
+			if bloomContains(bloom, a.Bytes()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, position := range fq.Topics {
+		if len(position) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range position {
+			if bloomContains(bloom, topic.Bytes()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// pollBlooms parses the current block number, and checks the logsBloom of
+// each block from "fromBlock" up to it. The logs are only fetched for blocks
+// that may contain matching logs, and "fromBlock" is moved past each block
+// that has been checked.
+func (e EthManager) pollBlooms(msg jsonrpcMessage) ([]subscriber.Event, bool) {
+	var res string
+	if err := json.Unmarshal(msg.Result, &res); err != nil {
+		log.Println("failed getting block number:", err)
+		return nil, false
+	}
+	head, err := hexutil.DecodeUint64(res)
+	if err != nil {
+		log.Println("failed decoding block number:", err)
+		return nil, false
+	}
+
+	fromBlock, err := hexutil.DecodeUint64(e.fq.FromBlock)
+	if err != nil {
+		fromBlock = head
+	}
+	toBlock := head
+	if toBlock >= fromBlock+maxBloomBlocks {
+		toBlock = fromBlock + maxBloomBlocks - 1
+	}
+
+	var released []ethLogResponse
+	var checked, skipped uint64
+	for n := fromBlock; n <= toBlock; n++ {
+		logs, err := e.getBloomLogs(n)
+		if err != nil {
+			log.Println("failed fetching logs:", err)
+			break
+		}
+		checked++
+		if logs == nil {
+			skipped++
+		}
+
+		released = append(released, e.processing(n)...)
+		for _, evt := range logs {
+			if e.cursor.processed(evt.blockNumber(), evt.logIndex()) {
+				continue
+			}
+			released = append(released, e.handleLog(evt)...)
+		}
+		e.fq.FromBlock = hexutil.EncodeUint64(n + 1)
+		e.saveProgress(n)
+	}
+
+	if checked > 0 {
+		e.bloom.checked += checked
+		e.bloom.skipped += skipped
+		log.Printf("Skipped %d of %d blocks using logsBloom (%d of %d in total)\n", skipped, checked, e.bloom.skipped, e.bloom.checked)
+	}
+
+	if e.confirmations > 0 {
+		released = append(released, e.releaseConfirmed(head)...)
+	}
+
+	return e.logsToEvents(released), true
+}
+
+// getBloomLogs fetches the header of the block with the number
+// provided, and the logs in the block if its logsBloom may contain
+// matching logs. Returns nil if the block was skipped.
+func (e EthManager) getBloomLogs(number uint64) ([]ethLogResponse, error) {
+	var block *ethBlockBloom
+	err := callJsonRpc(e.caller, "eth_getBlockByNumber", []interface{}{hexutil.EncodeUint64(number), false}, &block)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+
+	if !bloomMatches(block.LogsBloom, e.fq) {
+		return nil, nil
+	}
+
+	logs, err := e.getBlockLogs(common.HexToHash(block.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if logs == nil {
+		logs = []ethLogResponse{}
+	}
+	return logs, nil
+}
@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// newBloom returns a logsBloom containing the values provided.
+func newBloom(values ...[]byte) []byte {
+	bloom := make([]byte, bloomLength)
+	for _, value := range values {
+		hash := crypto.Keccak256(value)
+		for i := 0; i < 6; i += 2 {
+			bit := (uint(hash[i])<<8 | uint(hash[i+1])) & 2047
+			bloom[bloomLength-1-bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return bloom
+}
+
+func Test_bloomMatches(t *testing.T) {
+	address := common.HexToAddress("0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484")
+	topic := common.HexToHash(transferTopic)
+	other := common.HexToHash("0x01")
+
+	tests := []struct {
+		name  string
+		bloom []byte
+		fq    filterQuery
+		want  bool
+	}{
+		{"address and topic", newBloom(address.Bytes(), topic.Bytes()), filterQuery{Addresses: []common.Address{address}, Topics: [][]common.Hash{{topic}}}, true},
+		{"any of the topics", newBloom(address.Bytes(), topic.Bytes()), filterQuery{Topics: [][]common.Hash{{other, topic}}}, true},
+		{"any topic in a position", newBloom(address.Bytes()), filterQuery{Addresses: []common.Address{address}, Topics: [][]common.Hash{nil}}, true},
+		{"missing topic", newBloom(address.Bytes()), filterQuery{Addresses: []common.Address{address}, Topics: [][]common.Hash{{topic}}}, false},
+		{"missing address", newBloom(topic.Bytes()), filterQuery{Addresses: []common.Address{address}}, false},
+		{"empty bloom", newBloom(), filterQuery{Addresses: []common.Address{address}}, false},
+		{"invalid bloom", []byte{0x1}, filterQuery{Addresses: []common.Address{address}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, bloomMatches(tt.bloom, &tt.fq))
+		})
+	}
+}
+
+func TestEthManager_BloomStrategy(t *testing.T) {
+	address := common.HexToAddress("0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484")
+	blooms := map[string][]byte{
+		"0x6": newBloom(address.Bytes()),
+		"0x8": newBloom(address.Bytes()),
+	}
+
+	var fetched []string
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "eth_getBlockByNumber":
+			number := params[0].(string)
+			bloom, ok := blooms[number]
+			if !ok {
+				bloom = newBloom()
+			}
+			return ethBlockBloom{Number: number, Hash: "0x0" + number[2:], LogsBloom: bloom}, nil
+		case "eth_getLogs":
+			hash := params[0].(map[string]interface{})["blockHash"].(string)
+			fetched = append(fetched, hash)
+			if hash == common.HexToHash("0x06").Hex() {
+				return []ethLogResponse{{BlockNumber: "0x6", BlockHash: hash, LogIndex: "0x0", Address: address.Hex()}}, nil
+			}
+			return []ethLogResponse{}, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	_, err := createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Strategy: EthBloom}}, nil)
+	require.Error(t, err)
+
+	db := &mockStateStorer{}
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "test", Ethereum: store.EthSubscription{
+		Addresses: []string{address.Hex()},
+		Strategy:  EthBloom,
+	}}, db)
+	require.NoError(t, err)
+	e.caller = caller
+	e.fq.FromBlock = "0x5"
+
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, string(e.GetTriggerJson()))
+
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x8"}`))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x6/0x0"}, logPositions(t, events))
+
+	// Only blocks that may contain logs are fetched
+	assert.Equal(t, []string{common.HexToHash("0x06").Hex(), common.HexToHash("0x08").Hex()}, fetched)
+	assert.Equal(t, bloomStats{checked: 4, skipped: 2}, *e.bloom)
+	assert.Equal(t, "0x9", e.fq.FromBlock)
+	assert.Equal(t, uint64(8), db.cursor.BlockNumber)
+
+	t.Run("limits blocks per poll", func(t *testing.T) {
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + hexutil.EncodeUint64(1000) + `"}`))
+		require.True(t, ok)
+		assert.Empty(t, events)
+		assert.Equal(t, hexutil.EncodeUint64(9+maxBloomBlocks), e.fq.FromBlock)
+	})
+}
@@ -0,0 +1,317 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+	"math/big"
+)
+
+// EthCall is the kind of Ethereum subscriptions that poll
+// the return value of a contract function with "eth_call".
+const EthCall = "call"
+
+// EthCallManager implements the subscriber.JsonManager interface. It polls
+// a contract function over RPC, and triggers the job when the return value
+// changes. If a threshold is configured, the job is only triggered when the
+// first return value crosses it. If a deviation or a heartbeat is
+// configured, the job is triggered when the return value deviates
+// from the last value forwarded, or when the heartbeat has passed.
+type EthCallManager struct {
+	address   common.Address
+	method    *abi.Method
+	data      []byte
+	threshold *big.Int
+	deviation *deviationTrigger
+	last      []byte
+}
+
+// createEthCallManager creates a new instance of EthCallManager with the
+// provided connection type and store.EthSubscription config. The function
+// is called on the single address of the subscription. The last value
+// forwarded for a deviation is persisted using db, if provided.
+func createEthCallManager(p subscriber.Type, config store.Subscription, db StateStorer) (*EthCallManager, error) {
+	if p != subscriber.RPC {
+		return nil, errors.New("only RPC connections are allowed for the call kind")
+	}
+	if len(config.Ethereum.Addresses) != 1 {
+		return nil, errors.New("the call kind requires exactly 1 address")
+	}
+
+	method, err := parseFunctionABI(config.Ethereum.Function)
+	if err != nil {
+		return nil, fmt.Errorf("invalid function: %v", err)
+	}
+	if len(method.Outputs) == 0 {
+		return nil, errors.New("function has no return values")
+	}
+	if len(config.Ethereum.Args) != len(method.Inputs) {
+		return nil, fmt.Errorf("function expects %d args, got %d", len(method.Inputs), len(config.Ethereum.Args))
+	}
+
+	var args []interface{}
+	for i, input := range method.Inputs {
+		arg, err := parseAbiValue(input.Type, config.Ethereum.Args[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid arg %d: %v", i, err)
+		}
+		args = append(args, arg)
+	}
+
+	packed, err := method.Inputs.Pack(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var threshold *big.Int
+	if config.Ethereum.Threshold != "" {
+		output := method.Outputs[0].Type
+		if output.T != abi.IntTy && output.T != abi.UintTy {
+			return nil, errors.New("threshold requires the first return value to be an integer")
+		}
+
+		// Parsed like the args, and checked against the return type
+		var ok bool
+		threshold, ok = new(big.Int).SetString(config.Ethereum.Threshold, 0)
+		if !ok || !integerFits(output, threshold) {
+			return nil, fmt.Errorf("invalid threshold for %s: %s", output.String(), config.Ethereum.Threshold)
+		}
+	}
+
+	deviation, err := newDeviationTrigger(config, db)
+	if err != nil {
+		return nil, err
+	}
+	if deviation != nil {
+		if threshold != nil {
+			return nil, errors.New("a deviation or heartbeat cannot be combined with a threshold")
+		}
+		if deviation.field == "" {
+			deviation.field = argumentName(method.Outputs[0], 0)
+		}
+		if err := deviation.validateField(method.Outputs); err != nil {
+			return nil, err
+		}
+	}
+
+	return &EthCallManager{
+		address:   common.HexToAddress(config.Ethereum.Addresses[0]),
+		method:    method,
+		data:      append(method.ID(), packed...),
+		threshold: threshold,
+		deviation: deviation,
+	}, nil
+}
+
+// GetTriggerJson generates a JSON payload calling
+// the function at the latest block with "eth_call".
+func (e *EthCallManager) GetTriggerJson() []byte {
+	msg := jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "eth_call",
+	}
+
+	params, err := json.Marshal([]interface{}{
+		map[string]interface{}{
+			"to":   e.address,
+			"data": hexutil.Bytes(e.data),
+		},
+		"latest",
+	})
+	if err != nil {
+		return nil
+	}
+	msg.Params = params
+
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// GetTestJson calls the function, so that a job with
+// an invalid function or args is rejected on creation.
+func (e *EthCallManager) GetTestJson() []byte {
+	return e.GetTriggerJson()
+}
+
+// ParseTestResponse stores the initial return value
+// of the function, or returns the error from the call.
+func (e *EthCallManager) ParseTestResponse(data []byte) error {
+	result, err := parseCallResult(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := decodeValues(e.method.Outputs, result); err != nil {
+		return err
+	}
+
+	e.last = result
+	return nil
+}
+
+// ethCallEvent is the payload sent to the Chainlink
+// node when the return value of the function changes.
+type ethCallEvent struct {
+	Address  string                 `json:"address"`
+	Function string                 `json:"function"`
+	Old      map[string]interface{} `json:"old"`
+	New      map[string]interface{} `json:"new"`
+}
+
+// ParseResponse parses the result of "eth_call", and
+// returns an event if the job should be triggered.
+func (e *EthCallManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	result, err := parseCallResult(data)
+	if err != nil {
+		log.Println("failed calling function:", err)
+		return nil, false
+	}
+
+	if e.deviation != nil {
+		return e.checkDeviation(result)
+	}
+
+	if e.last == nil || bytes.Equal(result, e.last) {
+		e.last = result
+		return nil, true
+	}
+
+	values, err := decodeValues(e.method.Outputs, result)
+	if err != nil {
+		log.Println("failed decoding return value:", err)
+		return nil, false
+	}
+	oldValues, err := decodeValues(e.method.Outputs, e.last)
+	if err != nil {
+		log.Println("failed decoding return value:", err)
+		return nil, false
+	}
+
+	crossed, err := e.crossedThreshold(e.last, result)
+	e.last = result
+	if err != nil {
+		log.Println("failed comparing return value:", err)
+		return nil, false
+	}
+	if !crossed {
+		return nil, true
+	}
+
+	event, err := json.Marshal(ethCallEvent{
+		Address:  e.address.Hex(),
+		Function: e.method.Sig(),
+		Old:      oldValues,
+		New:      values,
+	})
+	if err != nil {
+		log.Println("marshal:", err)
+		return nil, false
+	}
+
+	return []subscriber.Event{event}, true
+}
+
+// checkDeviation returns an event if the field of the result
+// deviates from the last value forwarded, or if the heartbeat
+// has passed. The event includes the previous return value.
+func (e *EthCallManager) checkDeviation(result []byte) ([]subscriber.Event, bool) {
+	values, err := decodeValues(e.method.Outputs, result)
+	if err != nil {
+		log.Println("failed decoding return value:", err)
+		return nil, false
+	}
+	value, err := integerValue(values, e.deviation.field)
+	if err != nil {
+		log.Println("failed reading return value:", err)
+		return nil, false
+	}
+
+	var oldValues map[string]interface{}
+	if e.last != nil {
+		oldValues, err = decodeValues(e.method.Outputs, e.last)
+		if err != nil {
+			log.Println("failed decoding return value:", err)
+			return nil, false
+		}
+	}
+	e.last = result
+
+	if !e.deviation.check(value) {
+		return nil, true
+	}
+
+	event, err := json.Marshal(ethCallEvent{
+		Address:  e.address.Hex(),
+		Function: e.method.Sig(),
+		Old:      oldValues,
+		New:      values,
+	})
+	if err != nil {
+		log.Println("marshal:", err)
+		return nil, false
+	}
+
+	return []subscriber.Event{event}, true
+}
+
+// parseCallResult parses the result of an "eth_call" response.
+func parseCallResult(data []byte) ([]byte, error) {
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return nil, fmt.Errorf("eth_call returned error: %v", *msg.Error)
+	}
+
+	var result hexutil.Bytes
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// crossedThreshold returns true if the first return value crossed
+// the threshold between the two results, in either direction.
+// Without a threshold, every change is considered a crossing.
+func (e *EthCallManager) crossedThreshold(oldResult, newResult []byte) (bool, error) {
+	if e.threshold == nil {
+		return true, nil
+	}
+
+	oldValue, err := e.firstValue(oldResult)
+	if err != nil {
+		return false, err
+	}
+	newValue, err := e.firstValue(newResult)
+	if err != nil {
+		return false, err
+	}
+
+	return (oldValue.Cmp(e.threshold) < 0) != (newValue.Cmp(e.threshold) < 0), nil
+}
+
+func (e *EthCallManager) firstValue(result []byte) (*big.Int, error) {
+	values, err := e.method.Outputs.UnpackValues(result)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := new(big.Int).SetString(fmt.Sprint(values[0]), 10)
+	if !ok {
+		return nil, fmt.Errorf("return value is not an integer: %v", values[0])
+	}
+	return value, nil
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func callResponse(value int64) []byte {
+	return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"%s"}`, common.BigToHash(big.NewInt(value)).Hex()))
+}
+
+func TestCreateEthCallManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       subscriber.Type
+		config  store.EthSubscription
+		wantErr bool
+	}{
+		{"valid", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "balanceOf(address) returns (uint256)", Args: []string{"0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484"}, Threshold: "100"}, false},
+		{"WS endpoint", subscriber.WS, store.EthSubscription{Addresses: []string{"0x1"}, Function: "latestAnswer() returns (int256)"}, true},
+		{"multiple addresses", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1", "0x2"}, Function: "latestAnswer() returns (int256)"}, true},
+		{"invalid function", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "latestAnswer"}, true},
+		{"no return values", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "latestAnswer()"}, true},
+		{"missing args", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "balanceOf(address) returns (uint256)"}, true},
+		{"invalid args", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "balanceOf(address) returns (uint256)", Args: []string{"foo"}}, true},
+		{"invalid threshold", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "latestAnswer() returns (int256)", Threshold: "foo"}, true},
+		{"hex threshold", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "latestAnswer() returns (int256)", Threshold: "0x10"}, false},
+		{"negative threshold on unsigned", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "balance() returns (uint256)", Threshold: "-1"}, true},
+		{"threshold out of range", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "decimals() returns (uint8)", Threshold: "256"}, true},
+		{"threshold on non-integer", subscriber.RPC, store.EthSubscription{Addresses: []string{"0x1"}, Function: "owner() returns (address)", Threshold: "1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createEthCallManager(tt.p, store.Subscription{Ethereum: tt.config}, nil)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEthCallManager_GetTriggerJson(t *testing.T) {
+	e, err := createEthCallManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{
+		Addresses: []string{"0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484"},
+		Function:  "balanceOf(address) returns (uint256)",
+		Args:      []string{"0x0000000000000000000000000000000000000abc"},
+	}}, nil)
+	require.NoError(t, err)
+
+	want := `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"data":"0x70a082310000000000000000000000000000000000000000000000000000000000000abc","to":"0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484"},"latest"]}`
+	assert.Equal(t, want, string(e.GetTriggerJson()))
+	assert.Equal(t, want, string(e.GetTestJson()))
+}
+
+func TestEthCallManager_ParseResponse(t *testing.T) {
+	newManager := func(threshold string) *EthCallManager {
+		e, err := createEthCallManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{
+			Addresses: []string{"0x1"},
+			Function:  "latestAnswer() returns (int256 answer)",
+			Threshold: threshold,
+		}}, nil)
+		require.NoError(t, err)
+		return e
+	}
+
+	t.Run("triggers on change", func(t *testing.T) {
+		e := newManager("")
+		require.NoError(t, e.ParseTestResponse(callResponse(1)))
+
+		events, ok := e.ParseResponse(callResponse(1))
+		require.True(t, ok)
+		require.Empty(t, events)
+
+		events, ok = e.ParseResponse(callResponse(2))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+
+		var got ethCallEvent
+		require.NoError(t, json.Unmarshal(events[0], &got))
+		assert.Equal(t, ethCallEvent{
+			Address:  "0x0000000000000000000000000000000000000001",
+			Function: "latestAnswer()",
+			Old:      map[string]interface{}{"answer": "1"},
+			New:      map[string]interface{}{"answer": "2"},
+		}, got)
+	})
+
+	t.Run("triggers on crossing threshold", func(t *testing.T) {
+		e := newManager("10")
+		require.NoError(t, e.ParseTestResponse(callResponse(5)))
+
+		for _, tt := range []struct {
+			value   int64
+			trigger bool
+		}{{8, false}, {10, true}, {20, false}, {9, true}, {3, false}} {
+			events, ok := e.ParseResponse(callResponse(tt.value))
+			require.True(t, ok)
+			assert.Equal(t, tt.trigger, len(events) == 1, "value %d", tt.value)
+		}
+	})
+
+	t.Run("fails on call errors", func(t *testing.T) {
+		e := newManager("")
+		require.Error(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`)))
+		require.Error(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x01"}`)))
+
+		_, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`))
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,195 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+	"math/big"
+	"time"
+)
+
+// deviationTrigger decides whether a new value should trigger the job:
+// when it deviates from the last value that was forwarded by more than
+// the threshold percentage, or when the heartbeat interval has passed
+// since it was forwarded. The first value is always forwarded.
+//
+// The last forwarded value is persisted, so that a restart does not
+// trigger the job for a value that has been forwarded already.
+type deviationTrigger struct {
+	field     string
+	threshold *big.Rat
+	heartbeat time.Duration
+	db        StateStorer
+	job       string
+	last      *store.DeviationState
+	now       func() time.Time
+}
+
+// newDeviationTrigger creates a deviationTrigger from the subscription
+// config, or returns nil if the subscription has neither a deviation
+// nor a heartbeat. The last forwarded value is loaded from db, if provided.
+func newDeviationTrigger(config store.Subscription, db StateStorer) (*deviationTrigger, error) {
+	if config.Ethereum.Deviation == "" && config.Ethereum.Heartbeat == 0 {
+		return nil, nil
+	}
+
+	d := &deviationTrigger{
+		field:     config.Ethereum.Field,
+		heartbeat: time.Duration(config.Ethereum.Heartbeat) * time.Second,
+		db:        db,
+		job:       config.Job,
+		now:       time.Now,
+	}
+
+	if config.Ethereum.Deviation != "" {
+		threshold, ok := new(big.Rat).SetString(config.Ethereum.Deviation)
+		if !ok || threshold.Sign() < 0 {
+			return nil, fmt.Errorf("invalid deviation: %s", config.Ethereum.Deviation)
+		}
+		d.threshold = threshold
+	}
+
+	if db != nil {
+		last, err := db.LoadDeviationState(config.Job)
+		if err != nil {
+			log.Println("failed loading last forwarded value:", err)
+		}
+		d.last = last
+	}
+
+	return d, nil
+}
+
+// check returns true if the value should trigger the job,
+// and records it as the last forwarded value if so.
+func (d *deviationTrigger) check(value *big.Int) bool {
+	now := d.now()
+	if !d.shouldTrigger(value, now) {
+		return false
+	}
+
+	d.record(value.String(), now)
+	return true
+}
+
+// beat returns the last forwarded value, and true, if the heartbeat
+// has passed since it was forwarded. The value is not recorded as
+// forwarded again, so that the heartbeat is kept until it is sent.
+// Nothing is returned before a value was forwarded.
+func (d *deviationTrigger) beat() (string, bool) {
+	if !d.hasHeartbeat() || d.last == nil {
+		return "", false
+	}
+
+	if d.now().Sub(d.last.ForwardedAt) < d.heartbeat {
+		return "", false
+	}
+	return d.last.Value, true
+}
+
+// hasHeartbeat returns true if a heartbeat is configured.
+// A nil deviationTrigger has no heartbeat.
+func (d *deviationTrigger) hasHeartbeat() bool {
+	return d != nil && d.heartbeat > 0
+}
+
+func (d *deviationTrigger) record(value string, now time.Time) {
+	d.last = &store.DeviationState{Job: d.job, Value: value, ForwardedAt: now}
+	if d.db != nil {
+		if err := d.db.SaveDeviationState(d.last); err != nil {
+			log.Println("failed saving last forwarded value:", err)
+		}
+	}
+}
+
+func (d *deviationTrigger) shouldTrigger(value *big.Int, now time.Time) bool {
+	if d.last == nil {
+		return true
+	}
+
+	if d.heartbeat > 0 && now.Sub(d.last.ForwardedAt) >= d.heartbeat {
+		return true
+	}
+
+	if d.threshold == nil {
+		return false
+	}
+
+	last, ok := new(big.Int).SetString(d.last.Value, 10)
+	if !ok {
+		// The stored value cannot be compared, so start over
+		return true
+	}
+	if last.Sign() == 0 {
+		return value.Sign() != 0
+	}
+
+	// deviation = |value - last| / |last| * 100
+	diff := new(big.Int).Sub(value, last)
+	deviation := new(big.Rat).SetFrac(diff.Abs(diff), new(big.Int).Abs(last))
+	deviation.Mul(deviation, big.NewRat(100, 1))
+	return deviation.Cmp(d.threshold) > 0
+}
+
+// validateField returns an error if the trigger field
+// is not one of the integer arguments provided.
+func (d *deviationTrigger) validateField(args abi.Arguments) error {
+	for i, arg := range args {
+		if argumentName(arg, i) != d.field {
+			continue
+		}
+		if arg.Type.T != abi.IntTy && arg.Type.T != abi.UintTy {
+			return fmt.Errorf("deviation field %s is not an integer", d.field)
+		}
+		return nil
+	}
+	return fmt.Errorf("no field named %s for the deviation", d.field)
+}
+
+// ethHeartbeatEvent is the payload sent to the Chainlink node when
+// the heartbeat has passed without a log triggering the job. It holds
+// the last value forwarded, as the log it came from may be long gone.
+type ethHeartbeatEvent struct {
+	Heartbeat bool                   `json:"heartbeat"`
+	Decoded   map[string]interface{} `json:"decoded"`
+}
+
+// heartbeat returns an event with the last value forwarded
+// if the heartbeat has passed since it was forwarded, and
+// records the value as forwarded again. It must only be called
+// when the events returned are handed off to the subscriber.
+func (e EthManager) heartbeat() []subscriber.Event {
+	value, ok := e.deviation.beat()
+	if !ok {
+		return nil
+	}
+
+	event, err := json.Marshal(ethHeartbeatEvent{
+		Heartbeat: true,
+		Decoded:   map[string]interface{}{e.deviation.field: value},
+	})
+	if err != nil {
+		log.Println("marshal:", err)
+		return nil
+	}
+
+	e.deviation.record(value, e.deviation.now())
+	return []subscriber.Event{event}
+}
+
+// integerValue returns the value of a decoded field as an integer.
+func integerValue(values map[string]interface{}, field string) (*big.Int, error) {
+	v, ok := values[field]
+	if !ok {
+		return nil, fmt.Errorf("no field named %s", field)
+	}
+
+	value, ok := new(big.Int).SetString(fmt.Sprint(v), 10)
+	if !ok {
+		return nil, fmt.Errorf("field %s is not an integer", field)
+	}
+	return value, nil
+}
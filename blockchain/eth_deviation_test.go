@@ -0,0 +1,208 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestDeviationTrigger_shouldTrigger(t *testing.T) {
+	forwardedAt := time.Unix(1000, 0)
+
+	tests := []struct {
+		name      string
+		deviation string
+		heartbeat uint64
+		last      string
+		value     int64
+		elapsed   time.Duration
+		want      bool
+	}{
+		{"first value", "1", 0, "", 100, 0, true},
+		{"below deviation", "1", 0, "100", 101, 0, false},
+		{"above deviation", "1", 0, "100", 102, 0, true},
+		{"negative deviation", "1", 0, "100", 98, 0, true},
+		{"fractional deviation", "0.5", 0, "1000", 1006, 0, true},
+		{"unchanged zero", "1", 0, "0", 0, 0, false},
+		{"changed from zero", "1", 0, "0", 1, 0, true},
+		{"heartbeat not passed", "1", 60, "100", 100, 59 * time.Second, false},
+		{"heartbeat passed", "1", 60, "100", 100, 60 * time.Second, true},
+		{"heartbeat only", "", 60, "100", 200, time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newDeviationTrigger(store.Subscription{Ethereum: store.EthSubscription{
+				Deviation: tt.deviation,
+				Heartbeat: tt.heartbeat,
+			}}, nil)
+			require.NoError(t, err)
+			if tt.last != "" {
+				d.last = &store.DeviationState{Value: tt.last, ForwardedAt: forwardedAt}
+			}
+
+			assert.Equal(t, tt.want, d.shouldTrigger(big.NewInt(tt.value), forwardedAt.Add(tt.elapsed)))
+		})
+	}
+}
+
+func TestNewDeviationTrigger(t *testing.T) {
+	d, err := newDeviationTrigger(store.Subscription{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	_, err = newDeviationTrigger(store.Subscription{Ethereum: store.EthSubscription{Deviation: "foo"}}, nil)
+	require.Error(t, err)
+	_, err = newDeviationTrigger(store.Subscription{Ethereum: store.EthSubscription{Deviation: "-1"}}, nil)
+	require.Error(t, err)
+
+	// The last forwarded value is restored, and replaced
+	// by the next value that triggers the job
+	db := &mockStateStorer{deviation: &store.DeviationState{Job: "test", Value: "100", ForwardedAt: time.Now()}}
+	d, err = newDeviationTrigger(store.Subscription{Job: "test", Ethereum: store.EthSubscription{Deviation: "1"}}, db)
+	require.NoError(t, err)
+	assert.False(t, d.check(big.NewInt(100)))
+	assert.True(t, d.check(big.NewInt(110)))
+	assert.Equal(t, "110", db.deviation.Value)
+	assert.Equal(t, "test", db.deviation.Job)
+}
+
+func TestEthManager_Deviation(t *testing.T) {
+	eventABI := "AnswerUpdated(int256 indexed current, uint256 indexed roundId, uint256 updatedAt)"
+	event, err := parseEventABI(eventABI)
+	require.NoError(t, err)
+
+	answer := func(logIndex string, current int64, removed bool) ethLogResponse {
+		return ethLogResponse{
+			BlockNumber: "0x1",
+			LogIndex:    logIndex,
+			Topics:      []string{event.ID().Hex(), common.BigToHash(big.NewInt(current)).Hex(), common.BigToHash(big.NewInt(1)).Hex()},
+			Data:        common.BigToHash(big.NewInt(1591037782)).Hex(),
+			Removed:     removed,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config store.EthSubscription
+	}{
+		{"missing event ABI", store.EthSubscription{Deviation: "1", Field: "current"}},
+		{"unknown field", store.EthSubscription{EventABI: eventABI, Deviation: "1", Field: "answer"}},
+		{"non-integer field", store.EthSubscription{EventABI: "Foo(address bar)", Deviation: "1", Field: "bar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createEthManager(subscriber.RPC, store.Subscription{Ethereum: tt.config}, nil)
+			require.Error(t, err)
+		})
+	}
+
+	db := &mockStateStorer{}
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "test", Ethereum: store.EthSubscription{
+		EventABI:  eventABI,
+		Deviation: "1",
+		Field:     "current",
+	}}, db)
+	require.NoError(t, err)
+
+	events := e.logsToEvents([]ethLogResponse{
+		answer("0x0", 100, false),
+		answer("0x1", 101, false),
+		answer("0x2", 105, false),
+		answer("0x2", 105, true),
+		answer("0x3", 104, false),
+	})
+	require.Equal(t, []string{"0x1/0x0", "0x1/0x2"}, logPositions(t, events))
+	assert.Equal(t, "105", db.deviation.Value)
+}
+
+func TestEthManager_Heartbeat(t *testing.T) {
+	now := time.Unix(1591037782, 0)
+	db := &mockStateStorer{deviation: &store.DeviationState{Job: "test", Value: "105", ForwardedAt: now}}
+	e, err := createEthManager(subscriber.WS, store.Subscription{Job: "test", Ethereum: store.EthSubscription{
+		EventABI:  "AnswerUpdated(int256 indexed current, uint256 indexed roundId, uint256 updatedAt)",
+		Heartbeat: 60,
+		Field:     "current",
+	}}, db)
+	require.NoError(t, err)
+	e.deviation.now = func() time.Time { return now }
+
+	// Heads are subscribed to, for the heartbeat to be checked
+	assert.Contains(t, string(e.GetTriggerJson()), `"newHeads"`)
+
+	head := []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"heads","result":{"number":"0x2","hash":"0x02","parentHash":"0x01"}}}`)
+	events, ok := e.ParseResponse(head)
+	require.True(t, ok)
+	assert.Empty(t, events)
+
+	// The heartbeat passes without any log, but is
+	// kept while the responses cannot be parsed
+	now = now.Add(time.Minute)
+	_, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"internal error"}}`))
+	require.False(t, ok)
+	assert.Equal(t, now.Add(-time.Minute), db.deviation.ForwardedAt)
+
+	events, ok = e.ParseResponse(head)
+	require.True(t, ok)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"heartbeat":true,"decoded":{"current":"105"}}`, string(events[0]))
+	assert.Equal(t, now, db.deviation.ForwardedAt)
+
+	events, ok = e.ParseResponse(head)
+	require.True(t, ok)
+	assert.Empty(t, events)
+}
+
+func TestEthCallManager_Deviation(t *testing.T) {
+	config := store.EthSubscription{
+		Addresses: []string{"0x1"},
+		Function:  "latestAnswer() returns (int256 answer)",
+		Deviation: "1",
+		Heartbeat: 60,
+	}
+
+	_, err := createEthCallManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{
+		Addresses: config.Addresses,
+		Function:  config.Function,
+		Deviation: config.Deviation,
+		Threshold: "100",
+	}}, nil)
+	require.Error(t, err)
+
+	db := &mockStateStorer{}
+	e, err := createEthCallManager(subscriber.RPC, store.Subscription{Job: "test", Ethereum: config}, db)
+	require.NoError(t, err)
+	now := time.Unix(1000, 0)
+	e.deviation.now = func() time.Time { return now }
+
+	require.NoError(t, e.ParseTestResponse(callResponse(100)))
+
+	// The first value is forwarded
+	events, ok := e.ParseResponse(callResponse(100))
+	require.True(t, ok)
+	require.Len(t, events, 1)
+
+	events, ok = e.ParseResponse(callResponse(101))
+	require.True(t, ok)
+	require.Empty(t, events)
+
+	events, ok = e.ParseResponse(callResponse(102))
+	require.True(t, ok)
+	require.Len(t, events, 1)
+	var evt ethCallEvent
+	require.NoError(t, json.Unmarshal(events[0], &evt))
+	assert.Equal(t, map[string]interface{}{"answer": "101"}, evt.Old)
+	assert.Equal(t, map[string]interface{}{"answer": "102"}, evt.New)
+
+	now = now.Add(time.Minute)
+	events, ok = e.ParseResponse(callResponse(102))
+	require.True(t, ok)
+	require.Len(t, events, 1)
+	assert.Equal(t, "102", db.deviation.Value)
+	assert.Equal(t, now, db.deviation.ForwardedAt)
+}
@@ -0,0 +1,322 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// childLocation is where the child address is found in the creation
+// event of a factory: either in a topic, or in the 32 byte word at a
+// byte offset in the data.
+type childLocation struct {
+	topic  int
+	offset int
+}
+
+// parseChildLocation parses a child location of the
+// form "topic:<index>" or "data:<byte offset>".
+func parseChildLocation(location string) (childLocation, error) {
+	parts := strings.Split(location, ":")
+	if len(parts) != 2 {
+		return childLocation{}, fmt.Errorf("expected topic:<index> or data:<offset>, got %q", location)
+	}
+
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 0 {
+		return childLocation{}, fmt.Errorf("invalid index in %q", location)
+	}
+
+	switch parts[0] {
+	case "topic":
+		if n < 1 || n > 3 {
+			return childLocation{}, fmt.Errorf("topic index must be between 1 and 3, got %d", n)
+		}
+		return childLocation{topic: n}, nil
+	case "data":
+		return childLocation{offset: n}, nil
+	}
+	return childLocation{}, fmt.Errorf("expected topic:<index> or data:<offset>, got %q", location)
+}
+
+// address extracts the child address from the creation event.
+func (l childLocation) address(evt ethLogResponse) (common.Address, error) {
+	if l.topic > 0 {
+		if len(evt.Topics) <= l.topic {
+			return common.Address{}, fmt.Errorf("log has no topic %d", l.topic)
+		}
+		return common.HexToAddress(evt.Topics[l.topic]), nil
+	}
+
+	data, err := hexutil.Decode(evt.Data)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(data) < l.offset+common.HashLength {
+		return common.Address{}, fmt.Errorf("log data is too short for offset %d", l.offset)
+	}
+	return common.BytesToAddress(data[l.offset : l.offset+common.HashLength]), nil
+}
+
+// factoryTracker follows the children deployed by a factory contract.
+// The node is queried for the logs of both the factory and its children,
+// and the logs are matched against the subscription's filter locally.
+type factoryTracker struct {
+	address common.Address
+	event   common.Hash
+	child   childLocation
+	// addresses are the configured and discovered addresses
+	// matched by the subscription, and topics its topic filter
+	addresses map[common.Address]bool
+	topics    [][]common.Hash
+	db        StateStorer
+	job       string
+	// toBlock is the last block of the range being processed,
+	// which has already been queried without new children
+	toBlock uint64
+	// pending are the ranges of new children whose logs
+	// could not be fetched yet
+	pending []childFetch
+}
+
+// childFetch is a range of blocks queried without a new child,
+// from the block the child was created in.
+type childFetch struct {
+	address   common.Address
+	fromBlock uint64
+	toBlock   uint64
+}
+
+// newFactoryTracker creates a factoryTracker from the subscription config,
+// or returns nil if the subscription does not follow a factory. Addresses
+// discovered before a restart are loaded from db, if provided.
+func newFactoryTracker(config store.Subscription, addresses []common.Address, topics [][]common.Hash, db StateStorer) (*factoryTracker, error) {
+	if config.Ethereum.Factory == "" {
+		return nil, nil
+	}
+	if !common.IsHexAddress(config.Ethereum.Factory) {
+		return nil, fmt.Errorf("invalid factory address: %s", config.Ethereum.Factory)
+	}
+
+	event, err := parseEventABI(config.Ethereum.FactoryEvent)
+	if err != nil {
+		return nil, fmt.Errorf("invalid factory event: %v", err)
+	}
+	if event.Anonymous {
+		return nil, errors.New("factory event cannot be anonymous")
+	}
+
+	child, err := parseChildLocation(config.Ethereum.FactoryChild)
+	if err != nil {
+		return nil, fmt.Errorf("invalid factory child: %v", err)
+	}
+
+	f := &factoryTracker{
+		address:   common.HexToAddress(config.Ethereum.Factory),
+		event:     event.ID(),
+		child:     child,
+		addresses: make(map[common.Address]bool),
+		topics:    topics,
+		db:        db,
+		job:       config.Job,
+	}
+	for _, a := range addresses {
+		f.addresses[a] = true
+	}
+
+	if db != nil {
+		saved, err := db.LoadFactoryAddresses(config.Job)
+		if err != nil {
+			log.Println("failed loading factory addresses:", err)
+		}
+		for _, a := range saved {
+			f.addresses[common.HexToAddress(a)] = true
+		}
+	}
+
+	return f, nil
+}
+
+// filterQuery returns the query for the logs of both the factory and the
+// children. Only the first topic position can be combined, so the other
+// positions are matched locally.
+func (f *factoryTracker) filterQuery() *filterQuery {
+	var children []common.Address
+	for a := range f.addresses {
+		children = append(children, a)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return bytes.Compare(children[i][:], children[j][:]) < 0
+	})
+	fq := &filterQuery{Addresses: append([]common.Address{f.address}, children...)}
+
+	if len(f.topics) > 0 && len(f.topics[0]) > 0 {
+		first := append([]common.Hash{f.event}, f.topics[0]...)
+		fq.Topics = [][]common.Hash{first}
+	}
+	return fq
+}
+
+// processing sets the last block of the range being processed, up to
+// which the logs of children created in the range are fetched. The logs
+// of children that could not be fetched before are fetched again, and
+// the logs to forward are returned along with those of the range.
+func (e EthManager) processing(toBlock uint64) []ethLogResponse {
+	if e.factory == nil {
+		return nil
+	}
+	e.factory.toBlock = toBlock
+
+	var released []ethLogResponse
+	for len(e.factory.pending) > 0 {
+		fetch := e.factory.pending[0]
+		logs, err := e.getChildLogs(fetch)
+		if err != nil {
+			log.Println("failed fetching logs of child:", err)
+			break
+		}
+		e.factory.pending = e.factory.pending[1:]
+		for _, l := range logs {
+			released = append(released, e.handleLog(l)...)
+		}
+	}
+	return released
+}
+
+// firstPending returns the first block of the children's logs still to
+// be fetched, and false if there are none. A nil factoryTracker has none.
+func (f *factoryTracker) firstPending() (uint64, bool) {
+	if f == nil || len(f.pending) == 0 {
+		return 0, false
+	}
+
+	first := f.pending[0].fromBlock
+	for _, fetch := range f.pending[1:] {
+		if fetch.fromBlock < first {
+			first = fetch.fromBlock
+		}
+	}
+	return first, true
+}
+
+// isCreation returns true if the log is a creation event of the factory.
+func (f *factoryTracker) isCreation(evt ethLogResponse) bool {
+	return common.HexToAddress(evt.Address) == f.address &&
+		len(evt.Topics) > 0 && common.HexToHash(evt.Topics[0]) == f.event
+}
+
+// matches returns true if the log matches the subscription's own filter.
+func (f *factoryTracker) matches(evt ethLogResponse) bool {
+	if !f.addresses[common.HexToAddress(evt.Address)] {
+		return false
+	}
+
+	for i, position := range f.topics {
+		if len(position) == 0 {
+			continue
+		}
+		if i >= len(evt.Topics) {
+			return false
+		}
+
+		matched := false
+		for _, topic := range position {
+			if common.HexToHash(evt.Topics[i]) == topic {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds the child to the addresses matched by the subscription, and
+// saves it. Returns false if the child was already known.
+func (f *factoryTracker) add(child common.Address) bool {
+	if f.addresses[child] {
+		return false
+	}
+	f.addresses[child] = true
+
+	if f.db != nil {
+		if err := f.db.SaveFactoryAddress(f.job, child.Hex()); err != nil {
+			log.Println("failed saving factory address:", err)
+		}
+	}
+	return true
+}
+
+// handleFactoryLog follows the child deployed by a creation event of the
+// factory, and returns the logs the child emitted from its creation block
+// up to the end of the range being processed, as the logs of those blocks
+// have already been queried without it. If they cannot be fetched, they
+// are fetched again with the next range, and the progress is not saved
+// past the creation block meanwhile. Removed creation events are ignored,
+// and the child is still followed.
+func (e EthManager) handleFactoryLog(evt ethLogResponse) []ethLogResponse {
+	if evt.Removed {
+		return nil
+	}
+
+	child, err := e.factory.child.address(evt)
+	if err != nil {
+		log.Println("failed extracting child address:", err)
+		return nil
+	}
+	if !e.factory.add(child) {
+		return nil
+	}
+	log.Printf("Following %s, deployed by factory %s in tx %s\n", child.Hex(), e.factory.address.Hex(), evt.TransactionHash)
+
+	fq := e.factory.filterQuery()
+	e.fq.Addresses = fq.Addresses
+
+	// An installed filter does not pick up the new address,
+	// so a new filter is installed on the next poll
+	if e.strategy == EthFilter {
+		e.filter.id = ""
+	}
+
+	fetch := childFetch{address: child, fromBlock: evt.blockNumber(), toBlock: e.factory.toBlock}
+	if fetch.toBlock < fetch.fromBlock {
+		fetch.toBlock = fetch.fromBlock
+	}
+	logs, err := e.getChildLogs(fetch)
+	if err != nil {
+		log.Println("failed fetching logs of child, retrying with the next range:", err)
+		e.factory.pending = append(e.factory.pending, fetch)
+		return nil
+	}
+
+	var released []ethLogResponse
+	for _, l := range logs {
+		released = append(released, e.handleLog(l)...)
+	}
+	return released
+}
+
+// getChildLogs fetches the logs of a new child in the range provided.
+func (e EthManager) getChildLogs(fetch childFetch) ([]ethLogResponse, error) {
+	filter, err := filterQuery{
+		Addresses: []common.Address{fetch.address},
+		FromBlock: hexutil.EncodeUint64(fetch.fromBlock),
+		ToBlock:   hexutil.EncodeUint64(fetch.toBlock),
+	}.toMapInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []ethLogResponse
+	err = callJsonRpc(e.caller, "eth_getLogs", []interface{}{filter}, &logs)
+	return logs, err
+}
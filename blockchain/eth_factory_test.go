@@ -0,0 +1,200 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const (
+	factoryAddress = "0x00000000000000000000000000000000000000fa"
+	childCreated   = "ChildCreated(address indexed child)"
+)
+
+func Test_parseChildLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     childLocation
+		wantErr  bool
+	}{
+		{"topic:1", childLocation{topic: 1}, false},
+		{"data:32", childLocation{offset: 32}, false},
+		{"data:0", childLocation{}, false},
+		{"topic:0", childLocation{}, true},
+		{"topic:4", childLocation{}, true},
+		{"data:-1", childLocation{}, true},
+		{"data", childLocation{}, true},
+		{"log:1", childLocation{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			got, err := parseChildLocation(tt.location)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_childLocation_address(t *testing.T) {
+	evt := ethLogResponse{
+		Topics: []string{"0x01", "0x000000000000000000000000000000000000000000000000000000000000abcd"},
+		Data:   "0x" + strings.Repeat("00", 32) + "000000000000000000000000000000000000000000000000000000000000beef",
+	}
+
+	got, err := childLocation{topic: 1}.address(evt)
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0xabcd"), got)
+
+	got, err = childLocation{offset: 32}.address(evt)
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress("0xbeef"), got)
+
+	_, err = childLocation{topic: 2}.address(evt)
+	require.Error(t, err)
+	_, err = childLocation{offset: 33}.address(evt)
+	require.Error(t, err)
+}
+
+func TestCreateEthManager_Factory(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       subscriber.Type
+		config  store.EthSubscription
+		wantErr bool
+	}{
+		{"RPC", subscriber.RPC, store.EthSubscription{Factory: factoryAddress, FactoryEvent: childCreated, FactoryChild: "topic:1"}, false},
+		{"WS with heads", subscriber.WS, store.EthSubscription{Factory: factoryAddress, FactoryEvent: childCreated, FactoryChild: "topic:1", Strategy: EthHeads}, false},
+		{"WS without heads", subscriber.WS, store.EthSubscription{Factory: factoryAddress, FactoryEvent: childCreated, FactoryChild: "topic:1"}, true},
+		{"invalid factory", subscriber.RPC, store.EthSubscription{Factory: "0x123", FactoryEvent: childCreated, FactoryChild: "topic:1"}, true},
+		{"missing factory event", subscriber.RPC, store.EthSubscription{Factory: factoryAddress, FactoryChild: "topic:1"}, true},
+		{"missing factory child", subscriber.RPC, store.EthSubscription{Factory: factoryAddress, FactoryEvent: childCreated}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createEthManager(tt.p, store.Subscription{Ethereum: tt.config}, nil)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEthManager_Factory(t *testing.T) {
+	event, err := parseEventABI(childCreated)
+	require.NoError(t, err)
+	creation := fmt.Sprintf(`{"address":"%s","blockNumber":"0x2","blockHash":"0x02","logIndex":"0x0","topics":["%s","0x00000000000000000000000000000000000000000000000000000000000000c1"]}`, factoryAddress, event.ID().Hex())
+	childLog := func(address, blockNumber, logIndex, topic string) string {
+		return fmt.Sprintf(`{"address":"%s","blockNumber":"%s","logIndex":"%s","topics":["%s"]}`, address, blockNumber, logIndex, topic)
+	}
+
+	var childQueries []map[string]interface{}
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		if msg.Method != "eth_getLogs" {
+			return nil, errors.New("unexpected method")
+		}
+		var params []map[string]interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		childQueries = append(childQueries, params[0])
+		return json.RawMessage("[" + childLog("0x00000000000000000000000000000000000000c1", "0x2", "0x1", "0x0a") + "]"), nil
+	})
+
+	db := &mockStateStorer{addresses: []string{"0x00000000000000000000000000000000000000c0"}}
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "test", Ethereum: store.EthSubscription{
+		TopicFilter:  [][]string{{"0x0a"}},
+		Factory:      factoryAddress,
+		FactoryEvent: childCreated,
+		FactoryChild: "topic:1",
+	}}, db)
+	require.NoError(t, err)
+	e.caller = caller
+
+	// The query covers the factory and saved children
+	assert.Equal(t, []common.Address{
+		common.HexToAddress(factoryAddress),
+		common.HexToAddress("0xc0"),
+	}, e.fq.Addresses)
+	assert.Equal(t, [][]common.Hash{{event.ID(), common.HexToHash("0x0a")}}, e.fq.Topics)
+
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` +
+		childLog("0x00000000000000000000000000000000000000c0", "0x1", "0x0", "0x0a") + "," +
+		childLog("0x00000000000000000000000000000000000000c0", "0x1", "0x1", "0x0b") + "," +
+		childLog(factoryAddress, "0x1", "0x2", "0x0a") + "," +
+		creation + `]}`))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x1/0x0", "0x2/0x1"}, logPositions(t, events))
+
+	// The new child is queried from its creation block, then added to the filter
+	require.Len(t, childQueries, 1)
+	assert.Equal(t, []interface{}{"0x00000000000000000000000000000000000000c1"}, childQueries[0]["address"])
+	assert.Equal(t, "0x2", childQueries[0]["fromBlock"])
+	assert.Equal(t, "0x2", childQueries[0]["toBlock"])
+	assert.Equal(t, []string{"0x00000000000000000000000000000000000000c0", "0x00000000000000000000000000000000000000C1"}, db.addresses)
+	assert.Contains(t, e.fq.Addresses, common.HexToAddress("0xc1"))
+
+	t.Run("ignores known children", func(t *testing.T) {
+		_, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` + creation + `]}`))
+		require.True(t, ok)
+		assert.Len(t, childQueries, 1)
+	})
+	t.Run("queries new children up to the end of the range", func(t *testing.T) {
+		creation := fmt.Sprintf(`{"address":"%s","blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0","topics":["%s","0x00000000000000000000000000000000000000000000000000000000000000c2"]}`, factoryAddress, event.ID().Hex())
+		_, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` +
+			creation + "," +
+			childLog("0x00000000000000000000000000000000000000c0", "0x5", "0x0", "0x0a") + `]}`))
+		require.True(t, ok)
+		require.Len(t, childQueries, 2)
+		assert.Equal(t, []interface{}{"0x00000000000000000000000000000000000000c2"}, childQueries[1]["address"])
+		assert.Equal(t, "0x3", childQueries[1]["fromBlock"])
+		assert.Equal(t, "0x5", childQueries[1]["toBlock"])
+	})
+}
+
+func TestEthManager_Factory_ChildFetchFails(t *testing.T) {
+	event, err := parseEventABI(childCreated)
+	require.NoError(t, err)
+	creation := fmt.Sprintf(`{"address":"%s","blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0","topics":["%s","0x00000000000000000000000000000000000000000000000000000000000000c1"]}`, factoryAddress, event.ID().Hex())
+
+	failing := true
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		if msg.Method != "eth_getLogs" || failing {
+			return nil, errors.New("unexpected method")
+		}
+		return json.RawMessage(`[{"address":"0x00000000000000000000000000000000000000c1","blockNumber":"0x3","logIndex":"0x1","topics":["0x0a"]}]`), nil
+	})
+
+	db := &mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 1}}
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "test", Ethereum: store.EthSubscription{
+		Factory:      factoryAddress,
+		FactoryEvent: childCreated,
+		FactoryChild: "topic:1",
+	}}, db)
+	require.NoError(t, err)
+	e.caller = caller
+
+	// The child is followed, but its logs are not saved as processed
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` + creation + `]}`))
+	require.True(t, ok)
+	assert.Empty(t, events)
+	assert.Contains(t, e.fq.Addresses, common.HexToAddress("0xc1"))
+	assert.Equal(t, uint64(2), db.cursor.BlockNumber)
+
+	// The logs are fetched again with the next response
+	failing = false
+	events, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[]}`))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x3/0x1"}, logPositions(t, events))
+	assert.Equal(t, uint64(3), db.cursor.BlockNumber)
+}
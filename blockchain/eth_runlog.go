@@ -0,0 +1,120 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ugorji/go/codec"
+)
+
+// EthRunLog is the kind of Ethereum subscriptions that
+// are triggered by Chainlink OracleRequest events.
+const EthRunLog = "runlog"
+
+// oracleRequestEvent is the event emitted by the Chainlink Oracle contract
+// when a request is made. The request parameters are CBOR encoded in data.
+var oracleRequestEvent, _ = parseEventABI("OracleRequest(bytes32 indexed specId, address requester, bytes32 requestId, uint256 payment, address callbackAddr, bytes4 callbackFunctionId, uint256 cancelExpiration, uint256 dataVersion, bytes data)")
+
+// runLogSpecIDs returns the spec IDs a job can be requested with. The Oracle
+// contract receives the spec ID as bytes32, which is either the job ID string
+// itself, or the hex decoded job ID.
+func runLogSpecIDs(jobID string) []common.Hash {
+	specIDs := []common.Hash{bytesToSpecID([]byte(jobID))}
+	if bz, err := hex.DecodeString(jobID); err == nil && len(bz) > 0 {
+		specIDs = append(specIDs, bytesToSpecID(bz))
+	}
+	return specIDs
+}
+
+func bytesToSpecID(bz []byte) common.Hash {
+	if len(bz) > common.HashLength {
+		bz = bz[:common.HashLength]
+	}
+	return common.BytesToHash(common.RightPadBytes(bz, common.HashLength))
+}
+
+// decodeRunLog decodes an OracleRequest log into flat key/value
+// job params. The CBOR encoded request parameters are merged with
+// the fields needed to fulfill the request.
+func decodeRunLog(evt ethLogResponse, specIDs []common.Hash) (map[string]interface{}, error) {
+	request, err := decodeLog(oracleRequestEvent, evt)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	specID := common.HexToHash(request["specId"].(string))
+	for _, id := range specIDs {
+		if id == specID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("spec ID %s does not match job", specID.Hex())
+	}
+
+	params, err := decodeRunLogData(common.FromHex(request["data"].(string)))
+	if err != nil {
+		return nil, err
+	}
+
+	params["request_id"] = request["requestId"]
+	params["payment"] = request["payment"]
+	params["callback_address"] = request["callbackAddr"]
+	params["function"] = request["callbackFunctionId"]
+	params["expiration"] = request["cancelExpiration"]
+	return params, nil
+}
+
+// decodeRunLogData decodes the CBOR request parameters. The Chainlink
+// contracts encode the parameters as the items of a map, without the
+// surrounding map header, so an indefinite-length map is assumed.
+func decodeRunLogData(data []byte) (map[string]interface{}, error) {
+	buf := append([]byte{0xbf}, data...)
+	buf = append(buf, 0xff)
+
+	var params map[interface{}]interface{}
+	err := codec.NewDecoderBytes(buf, new(codec.CborHandle)).Decode(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := cborToJson(params)
+	if err != nil {
+		return nil, err
+	}
+	return converted.(map[string]interface{}), nil
+}
+
+// cborToJson converts decoded CBOR maps to maps with
+// string keys, so that they can be marshalled to JSON.
+func cborToJson(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, val := range v {
+			str, ok := key.(string)
+			if !ok {
+				return nil, errors.New("non-string key in CBOR map")
+			}
+			converted, err := cborToJson(val)
+			if err != nil {
+				return nil, err
+			}
+			m[str] = converted
+		}
+		return m, nil
+	case []interface{}:
+		for i, val := range v {
+			converted, err := cborToJson(val)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,145 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"math/big"
+	"testing"
+)
+
+const runLogJobID = "4c7b7ffb66b344fbaa64995af81e355a"
+
+func oracleRequestLog(t *testing.T, specID common.Hash, params map[string]interface{}) ethLogResponse {
+	var buf []byte
+	require.NoError(t, codec.NewEncoderBytes(&buf, new(codec.CborHandle)).Encode(params))
+
+	data, err := oracleRequestEvent.Inputs.NonIndexed().Pack(
+		common.HexToAddress("0x1"),
+		common.HexToHash("0xabc"),
+		big.NewInt(1000000000000000000),
+		common.HexToAddress("0x2"),
+		[4]byte{0x12, 0x34, 0x56, 0x78},
+		big.NewInt(1586163522),
+		big.NewInt(1),
+		// Chainlink contracts omit the map header
+		buf[1:],
+	)
+	require.NoError(t, err)
+
+	return ethLogResponse{
+		BlockNumber: "0x1",
+		LogIndex:    "0x0",
+		Topics:      []string{oracleRequestEvent.ID().Hex(), specID.Hex()},
+		Data:        hexutil.Encode(data),
+	}
+}
+
+func Test_runLogSpecIDs(t *testing.T) {
+	assert.Equal(t, []common.Hash{
+		common.HexToHash("0x3463376237666662363662333434666261613634393935616638316533353561"),
+		common.HexToHash("0x4c7b7ffb66b344fbaa64995af81e355a00000000000000000000000000000000"),
+	}, runLogSpecIDs(runLogJobID))
+
+	assert.Equal(t, []common.Hash{
+		common.HexToHash("0x6a6f620000000000000000000000000000000000000000000000000000000000"),
+	}, runLogSpecIDs("job"))
+}
+
+func Test_decodeRunLog(t *testing.T) {
+	specIDs := runLogSpecIDs(runLogJobID)
+
+	t.Run("decodes request", func(t *testing.T) {
+		evt := oracleRequestLog(t, specIDs[1], map[string]interface{}{
+			"get":  "https://example.com",
+			"path": []interface{}{"USD", "last"},
+		})
+
+		params, err := decodeRunLog(evt, specIDs)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"get":              "https://example.com",
+			"path":             []interface{}{"USD", "last"},
+			"request_id":       "0x0000000000000000000000000000000000000000000000000000000000000abc",
+			"payment":          "1000000000000000000",
+			"callback_address": "0x0000000000000000000000000000000000000002",
+			"function":         "0x12345678",
+			"expiration":       "1586163522",
+		}, params)
+	})
+
+	t.Run("fails on other spec ID", func(t *testing.T) {
+		evt := oracleRequestLog(t, common.HexToHash("0x1"), map[string]interface{}{})
+		_, err := decodeRunLog(evt, specIDs)
+		require.Error(t, err)
+	})
+}
+
+func Test_decodeRunLogData(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		params, err := decodeRunLogData(nil)
+		require.NoError(t, err)
+		assert.Empty(t, params)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := decodeRunLogData([]byte{0x63, 'g', 'e'})
+		require.Error(t, err)
+	})
+
+	t.Run("non-string key", func(t *testing.T) {
+		_, err := decodeRunLogData([]byte{0x01, 0x02})
+		require.Error(t, err)
+	})
+}
+
+func TestEthManager_RunLog(t *testing.T) {
+	e, err := createEthManager(subscriber.WS, store.Subscription{
+		Job:      runLogJobID,
+		Ethereum: store.EthSubscription{Kind: EthRunLog, Addresses: []string{"0x3"}, Topics: []string{"ignored"}},
+	}, nil)
+	require.NoError(t, err)
+
+	t.Run("filters on OracleRequest for job", func(t *testing.T) {
+		assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":["0x0000000000000000000000000000000000000003"],"fromBlock":"0x0","toBlock":"latest","topics":[["0xd8d7ecc4800d25fa53ce0372f13a416d98907a7ef3d8d3bdd79cf4fe75529c65"],["0x3463376237666662363662333434666261613634393935616638316533353561","0x4c7b7ffb66b344fbaa64995af81e355a00000000000000000000000000000000"]]}]}`, string(e.GetTriggerJson()))
+	})
+
+	t.Run("sends flat params", func(t *testing.T) {
+		evt := oracleRequestLog(t, e.specIDs[0], map[string]interface{}{"times": 100})
+		res, err := json.Marshal(ethSubscribeResponse{Subscription: "test", Result: mustMarshal(t, evt)})
+		require.NoError(t, err)
+		msg, err := json.Marshal(jsonrpcMessage{Version: "2.0", Method: "eth_subscription", Params: res})
+		require.NoError(t, err)
+
+		events, ok := e.ParseResponse(msg)
+		require.True(t, ok)
+		require.Len(t, events, 1)
+
+		var params map[string]interface{}
+		require.NoError(t, json.Unmarshal(events[0], &params))
+		assert.Equal(t, float64(100), params["times"])
+		assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000abc", params["request_id"])
+
+		// Removed requests are not sent
+		evt.Removed = true
+		evt.LogIndex = "0x1"
+		events = e.logsToEvents([]ethLogResponse{evt})
+		require.Empty(t, events)
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		_, err := createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Kind: "foo"}}, nil)
+		require.Error(t, err)
+	})
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	bz, err := json.Marshal(v)
+	require.NoError(t, err)
+	return bz
+}
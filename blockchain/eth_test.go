@@ -1,6 +1,9 @@
 package blockchain
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/magiconair/properties/assert"
@@ -61,15 +64,86 @@ func TestCreateEthFilterMessage(t *testing.T) {
 			subscriber.RPC,
 			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"address":["0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484"],"fromBlock":"latest","toBlock":"latest","topics":[["0x0000000000000000000000000000000000000000000000000000000000000abc","0x0000000000000000000000000000000000000000000000000000000000000def"]]}]}`),
 		},
+		{
+			"positional topics",
+			store.EthSubscription{TopicFilter: [][]string{{"abc"}, nil, {"def", "", "123"}}, Topics: []string{"ignored"}},
+			subscriber.WS,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":null,"fromBlock":"0x0","toBlock":"latest","topics":[["0x0000000000000000000000000000000000000000000000000000000000000abc"],null,["0x0000000000000000000000000000000000000000000000000000000000000def","0x0000000000000000000000000000000000000000000000000000000000000123"]]}]}`),
+		},
+		{
+			"positional topics with empty wildcard",
+			store.EthSubscription{TopicFilter: [][]string{{}, {"abc"}}},
+			subscriber.RPC,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"address":null,"fromBlock":"latest","toBlock":"latest","topics":[null,["0x0000000000000000000000000000000000000000000000000000000000000abc"]]}]}`),
+		},
+		{
+			"WS with confirmations",
+			store.EthSubscription{Confirmations: 3},
+			subscriber.WS,
+			[]byte(`[{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":null,"fromBlock":"0x0","toBlock":"latest","topics":[null]}]},{"jsonrpc":"2.0","id":2,"method":"eth_subscribe","params":["newHeads"]}]`),
+		},
+		{
+			"heads strategy",
+			store.EthSubscription{Strategy: EthHeads, Confirmations: 2},
+			subscriber.WS,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`),
+		},
+		{
+			"event signature",
+			store.EthSubscription{EventABI: "Transfer(address indexed,address indexed,uint256)", TopicFilter: [][]string{{}, {"def"}}},
+			subscriber.WS,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":null,"fromBlock":"0x0","toBlock":"latest","topics":[["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"],["0x0000000000000000000000000000000000000000000000000000000000000def"]]}]}`),
+		},
+		{
+			"event signature as first topic",
+			store.EthSubscription{EventABI: "Transfer(address indexed,address indexed,uint256)", TopicFilter: [][]string{{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"}, {"def"}}},
+			subscriber.WS,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":null,"fromBlock":"0x0","toBlock":"latest","topics":[["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"],["0x0000000000000000000000000000000000000000000000000000000000000def"]]}]}`),
+		},
+		{
+			"anonymous event ABI",
+			store.EthSubscription{EventABI: `{"type":"event","name":"Foo","anonymous":true,"inputs":[]}`, Topics: []string{"abc"}},
+			subscriber.WS,
+			[]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":null,"fromBlock":"0x0","toBlock":"latest","topics":[["0x0000000000000000000000000000000000000000000000000000000000000abc"]]}]}`),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := createEthManager(tt.p, store.Subscription{Ethereum: tt.args}).GetTriggerJson(); !reflect.DeepEqual(got, tt.want) {
+			e, err := createEthManager(tt.p, store.Subscription{Ethereum: tt.args}, nil)
+			require.NoError(t, err)
+			if got := e.GetTriggerJson(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GetTriggerJson() = %s, want %s", got, tt.want)
 			}
 		})
 	}
 
+	t.Run("has invalid strategy", func(t *testing.T) {
+		_, err := createEthManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{Strategy: EthHeads}}, nil)
+		require.Error(t, err)
+		_, err = createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Strategy: EthFilter}}, nil)
+		require.Error(t, err)
+		_, err = createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Strategy: "foo"}}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("has invalid event ABI", func(t *testing.T) {
+		_, err := createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{EventABI: "Transfer"}}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("has first topic other than the event signature", func(t *testing.T) {
+		_, err := createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{
+			EventABI:    "Transfer(address indexed,address indexed,uint256)",
+			TopicFilter: [][]string{{"abc"}, {"def"}},
+		}}, nil)
+		require.Error(t, err)
+		_, err = createEthManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{
+			EventABI: "Transfer(address indexed,address indexed,uint256)",
+			Topics:   []string{"abc"},
+		}}, nil)
+		require.Error(t, err)
+	})
+
 	t.Run("has invalid filter query", func(t *testing.T) {
 		blockHash := common.HexToHash("0xabc")
 		got := EthManager{fq: &filterQuery{BlockHash: &blockHash, FromBlock: "0x1", ToBlock: "0x2"}}.GetTriggerJson()
@@ -297,6 +371,14 @@ func TestEthManager_ParseResponse(t *testing.T) {
 			true,
 			"0x1",
 		},
+		{
+			"forwards removed log from WS response",
+			fields{fq: &filterQuery{}, p: subscriber.WS},
+			args{data: []byte(`{"jsonrpc":"2.0","id":1,"params":{"subscription":"test","result":{"data":"test","removed":true}}}`)},
+			[]subscriber.Event{subscriber.Event(`{"logIndex":"","blockNumber":"","blockHash":"","transactionHash":"","transactionIndex":"","address":"","data":"test","topics":null,"removed":true}`)},
+			true,
+			"",
+		},
 		{
 			"does not update fromBlock in the past from RPC payload",
 			fields{fq: &filterQuery{FromBlock: "0x1"}, p: subscriber.RPC},
@@ -326,6 +408,183 @@ func TestEthManager_ParseResponse(t *testing.T) {
 	}
 }
 
+// logPositions returns the "blockNumber/logIndex"
+// of each ethLogResponse event provided.
+func logPositions(t *testing.T, events []subscriber.Event) []string {
+	var positions []string
+	for _, event := range events {
+		var evt ethLogResponse
+		require.NoError(t, json.Unmarshal(event, &evt))
+		positions = append(positions, evt.BlockNumber+"/"+evt.LogIndex)
+	}
+	return positions
+}
+
+func TestEthManager_Confirmations(t *testing.T) {
+	canonical := map[string]string{
+		"0x1": "0x01",
+		"0x2": "0x02",
+		"0x3": "0x03",
+	}
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		switch msg.Method {
+		case "eth_blockNumber":
+			return "0x4", nil
+		case "eth_getBlockByNumber":
+			var params []interface{}
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+			return ethHeadResponse{Number: params[0].(string), Hash: canonical[params[0].(string)]}, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	logMsg := func(blockNumber, blockHash, logIndex string, removed bool) []byte {
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"logs","result":{"blockNumber":"%s","blockHash":"%s","logIndex":"%s","removed":%v}}}`, blockNumber, blockHash, logIndex, removed))
+	}
+	headMsg := func(number string) []byte {
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"heads","result":{"number":"%s","hash":"0xabc","parentHash":"0xdef"}}}`, number))
+	}
+	t.Run("holds WS logs until confirmed", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, p: subscriber.WS, caller: caller, confirmations: 2, queue: &confirmationQueue{}}
+
+		events, ok := e.ParseResponse(logMsg("0x2", "0x02", "0x0", false))
+		require.True(t, ok)
+		require.Empty(t, events)
+		events, ok = e.ParseResponse(logMsg("0x1", "0x01", "0x1", false))
+		require.True(t, ok)
+		require.Empty(t, events)
+
+		events, ok = e.ParseResponse(headMsg("0x2"))
+		require.True(t, ok)
+		require.Empty(t, events)
+
+		events, ok = e.ParseResponse(headMsg("0x3"))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x1/0x1"}, logPositions(t, events))
+
+		events, ok = e.ParseResponse(headMsg("0x4"))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x2/0x0"}, logPositions(t, events))
+	})
+
+	t.Run("drops removed and reorged logs", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, p: subscriber.WS, caller: caller, confirmations: 1, queue: &confirmationQueue{}}
+
+		_, _ = e.ParseResponse(logMsg("0x1", "0x01", "0x0", false))
+		_, _ = e.ParseResponse(logMsg("0x2", "0xbad", "0x0", false))
+		_, _ = e.ParseResponse(logMsg("0x3", "0x03", "0x0", false))
+
+		events, ok := e.ParseResponse(logMsg("0x1", "0x01", "0x0", true))
+		require.True(t, ok)
+		require.Empty(t, events)
+
+		events, ok = e.ParseResponse(headMsg("0x4"))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x3/0x0"}, logPositions(t, events))
+	})
+
+	t.Run("forwards removed logs that were already released", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, p: subscriber.WS, caller: caller, confirmations: 1, queue: &confirmationQueue{}}
+
+		events, ok := e.ParseResponse(logMsg("0x1", "0x01", "0x0", true))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		require.Contains(t, string(events[0]), `"removed":true`)
+	})
+
+	t.Run("releases confirmed RPC logs", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, p: subscriber.RPC, caller: caller, confirmations: 2, queue: &confirmationQueue{}}
+
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x1","blockHash":"0x01","logIndex":"0x0"},{"blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0"}]}`))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x1/0x0"}, logPositions(t, events))
+		require.Len(t, e.queue.logs, 1)
+	})
+	t.Run("queries RPC logs again from reorged blocks", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, p: subscriber.RPC, caller: caller, confirmations: 1, queue: &confirmationQueue{}}
+
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x2","blockHash":"0xbad","logIndex":"0x0"},{"blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0"}]}`))
+		require.True(t, ok)
+		require.Empty(t, events)
+		require.Equal(t, "0x2", e.fq.FromBlock)
+
+		// The log of the canonical block is released, and
+		// the log of the following block is not sent twice
+		events, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x2","blockHash":"0x02","logIndex":"0x0"},{"blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0"}]}`))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x2/0x0", "0x3/0x0"}, logPositions(t, events))
+		require.Empty(t, e.queue.logs)
+	})
+}
+
+func TestEthManager_Backfill(t *testing.T) {
+	logs := []ethLogResponse{
+		{BlockNumber: "0x2", BlockHash: "0x02", LogIndex: "0x0"},
+		{BlockNumber: "0x2", BlockHash: "0x02", LogIndex: "0x1"},
+		{BlockNumber: "0x3", BlockHash: "0x03", LogIndex: "0x0"},
+	}
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		switch msg.Method {
+		case "eth_blockNumber":
+			return "0x4", nil
+		case "eth_getLogs":
+			var params []map[string]interface{}
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+			require.Equal(t, "0x2", params[0]["fromBlock"])
+			require.Equal(t, "0x4", params[0]["toBlock"])
+			return logs, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	t.Run("backfills from stored cursor", func(t *testing.T) {
+		db := &mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 1, LogIndex: 1}}
+		e, err := createEthManager(subscriber.WS, store.Subscription{Job: "job"}, db)
+		require.NoError(t, err)
+		e.caller = caller
+
+		events := e.Backfill()
+		require.Equal(t, []string{"0x2/0x1", "0x3/0x0"}, logPositions(t, events))
+		require.Equal(t, uint64(4), db.cursor.BlockNumber)
+
+		// Logs already backfilled are not sent twice
+		got, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"blockNumber":"0x3","blockHash":"0x03","logIndex":"0x0"}}}`))
+		require.True(t, ok)
+		require.Empty(t, got)
+
+		got, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"blockNumber":"0x5","blockHash":"0x05","logIndex":"0x3"}}}`))
+		require.True(t, ok)
+		require.Len(t, got, 1)
+		require.Equal(t, uint64(4), db.cursor.BlockNumber)
+		require.Equal(t, uint64(4), db.cursor.LogIndex)
+	})
+
+	t.Run("stores head without cursor", func(t *testing.T) {
+		db := &mockStateStorer{}
+		e, err := createEthManager(subscriber.WS, store.Subscription{Job: "job"}, db)
+		require.NoError(t, err)
+		e.caller = caller
+
+		require.Empty(t, e.Backfill())
+		require.Equal(t, uint64(4), db.cursor.BlockNumber)
+	})
+
+	t.Run("resumes RPC queries from stored cursor", func(t *testing.T) {
+		db := &mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 1}}
+		e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "job"}, db)
+		require.NoError(t, err)
+
+		require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`)))
+		require.Equal(t, "0x2", e.fq.FromBlock)
+
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x7","logIndex":"0x0"}]}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		require.Equal(t, "0x8", e.fq.FromBlock)
+		require.Equal(t, uint64(7), db.cursor.BlockNumber)
+	})
+}
+
 func Test_filterQuery_toMapInterface(t *testing.T) {
 	type fields struct {
 		BlockHash *common.Hash
@@ -408,3 +667,243 @@ func Test_filterQuery_toMapInterface(t *testing.T) {
 		})
 	}
 }
+
+// rangeLimitedCaller responds to "eth_getLogs" with a log for
+// every block, rejecting queries for more than limit blocks.
+func rangeLimitedCaller(t *testing.T, head, limit uint64, failAt uint64) mockCaller {
+	return func(msg jsonrpcMessage) (interface{}, error) {
+		switch msg.Method {
+		case "eth_blockNumber":
+			return hexutil.EncodeUint64(head), nil
+		case "eth_getLogs":
+			var params []map[string]interface{}
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+			from, err := hexutil.DecodeUint64(params[0]["fromBlock"].(string))
+			require.NoError(t, err)
+			to, err := hexutil.DecodeUint64(params[0]["toBlock"].(string))
+			require.NoError(t, err)
+
+			if to-from+1 > limit {
+				return nil, fmt.Errorf("query returned more than %d results", limit)
+			}
+			if from <= failAt && failAt <= to {
+				return nil, errors.New("internal error")
+			}
+
+			var logs []ethLogResponse
+			for n := from; n <= to; n++ {
+				logs = append(logs, ethLogResponse{BlockNumber: hexutil.EncodeUint64(n), BlockHash: hexutil.EncodeUint64(n), LogIndex: "0x0"})
+			}
+			return logs, nil
+		}
+		return nil, errors.New("unexpected method")
+	}
+}
+
+func TestEthManager_getLogsInChunks(t *testing.T) {
+	t.Run("halves and grows chunks", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, caller: rangeLimitedCaller(t, 5, 2, 0), logRange: &logRange{size: 8}}
+
+		var chunks [][2]uint64
+		err := e.getLogsInChunks(1, 5, func(logs []ethLogResponse, toBlock uint64) {
+			chunks = append(chunks, [2]uint64{logs[0].blockNumber(), toBlock})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, chunks, [][2]uint64{{1, 2}, {3, 4}, {5, 5}})
+		assert.Equal(t, e.logRange.size, uint64(8))
+	})
+
+	t.Run("stops on other errors", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, caller: rangeLimitedCaller(t, 5, 2, 3), logRange: &logRange{size: 2}}
+
+		var chunks [][2]uint64
+		err := e.getLogsInChunks(1, 5, func(logs []ethLogResponse, toBlock uint64) {
+			chunks = append(chunks, [2]uint64{logs[0].blockNumber(), toBlock})
+		})
+		require.Error(t, err)
+		assert.Equal(t, chunks, [][2]uint64{{1, 2}})
+	})
+
+	t.Run("fails when single blocks are rejected", func(t *testing.T) {
+		e := EthManager{fq: &filterQuery{}, caller: rangeLimitedCaller(t, 5, 0, 0), logRange: &logRange{size: 2}}
+		err := e.getLogsInChunks(1, 5, func([]ethLogResponse, uint64) {
+			t.Error("unexpected chunk")
+		})
+		require.Error(t, err)
+	})
+}
+
+func Test_isLogRangeError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{`eth_getLogs returned error: {"code":-32005,"message":"query returned more than 10000 results"}`, true},
+		{"query returned more than 10000 results", true},
+		{"exceed maximum block range: 5000", true},
+		{"eth_getLogs is limited to a 10,000 block range", true},
+		{"Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range", true},
+		{"rate limit exceeded", false},
+		{"daily request count exceeded, request rate limited", false},
+		{"context deadline exceeded (Client.Timeout exceeded while awaiting headers)", false},
+		{"i/o timeout", false},
+		{"internal error", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isLogRangeError(errors.New(tt.err)), tt.err)
+	}
+}
+
+func TestEthManager_ParseResponse_CatchUp(t *testing.T) {
+	db := &mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 1}}
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "job"}, db)
+	require.NoError(t, err)
+	e.caller = rangeLimitedCaller(t, 6, 2, 6)
+	require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x6"}`)))
+
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"query returned more than 10000 results"}}`))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x2/0x0", "0x3/0x0", "0x4/0x0", "0x5/0x0"}, logPositions(t, events))
+
+	// Only fully fetched chunks are skipped
+	assert.Equal(t, e.fq.FromBlock, "0x6")
+	assert.Equal(t, db.cursor.BlockNumber, uint64(5))
+
+	_, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"internal error"}}`))
+	require.False(t, ok)
+}
+
+func TestEthManager_HeadsStrategy(t *testing.T) {
+	failing, failingHead := false, false
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		if msg.Method != "eth_getLogs" || failing {
+			return nil, errors.New("unexpected method")
+		}
+
+		var params []map[string]interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		if blockHash, ok := params[0]["blockHash"].(string); ok {
+			if failingHead {
+				return nil, errors.New("unavailable")
+			}
+			number := common.HexToHash(blockHash).Big().Uint64()
+			return []ethLogResponse{{BlockNumber: hexutil.EncodeUint64(number), BlockHash: blockHash, LogIndex: "0x0"}}, nil
+		}
+
+		from, err := hexutil.DecodeUint64(params[0]["fromBlock"].(string))
+		require.NoError(t, err)
+		to, err := hexutil.DecodeUint64(params[0]["toBlock"].(string))
+		require.NoError(t, err)
+		var logs []ethLogResponse
+		for n := from; n <= to; n++ {
+			logs = append(logs, ethLogResponse{BlockNumber: hexutil.EncodeUint64(n), BlockHash: common.BigToHash(new(big.Int).SetUint64(n)).Hex(), LogIndex: "0x0"})
+		}
+		return logs, nil
+	})
+
+	db := &mockStateStorer{}
+	e, err := createEthManager(subscriber.WS, store.Subscription{Job: "job", Ethereum: store.EthSubscription{Strategy: EthHeads}}, db)
+	require.NoError(t, err)
+	e.caller = caller
+
+	head := func(number uint64) []byte {
+		return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"number":"%s","hash":"%s","parentHash":"0x01"}}}`,
+			hexutil.EncodeUint64(number), common.BigToHash(new(big.Int).SetUint64(number)).Hex()))
+	}
+
+	t.Run("fetches logs by block hash", func(t *testing.T) {
+		events, ok := e.ParseResponse(head(3))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		require.Equal(t, uint64(3), db.cursor.BlockNumber)
+	})
+
+	t.Run("fetches logs of skipped blocks", func(t *testing.T) {
+		events, ok := e.ParseResponse(head(6))
+		require.True(t, ok)
+		var blocks []string
+		for _, event := range events {
+			var evt ethLogResponse
+			require.NoError(t, json.Unmarshal(event, &evt))
+			blocks = append(blocks, evt.BlockNumber)
+		}
+		require.Equal(t, []string{"0x4", "0x5", "0x6"}, blocks)
+		require.Equal(t, uint64(6), db.cursor.BlockNumber)
+	})
+
+	t.Run("does not advance on failure", func(t *testing.T) {
+		failing = true
+		events, ok := e.ParseResponse(head(8))
+		require.True(t, ok)
+		require.Empty(t, events)
+		require.Equal(t, uint64(6), db.cursor.BlockNumber)
+
+		failing = false
+		events, ok = e.ParseResponse(head(9))
+		require.True(t, ok)
+		require.Len(t, events, 3)
+		require.Equal(t, uint64(9), db.cursor.BlockNumber)
+	})
+	t.Run("fetches skipped blocks again when the head fails", func(t *testing.T) {
+		failingHead = true
+		events, ok := e.ParseResponse(head(11))
+		require.True(t, ok)
+		require.Empty(t, events)
+
+		failingHead = false
+		events, ok = e.ParseResponse(head(12))
+		require.True(t, ok)
+		require.Len(t, events, 3)
+		require.Equal(t, uint64(12), db.cursor.BlockNumber)
+	})
+}
+
+func TestEthManager_FilterStrategy(t *testing.T) {
+	filterCaller := func(head uint64) mockCaller {
+		logs := rangeLimitedCaller(t, head, 100, 0)
+		return func(msg jsonrpcMessage) (interface{}, error) {
+			if msg.Method == "eth_newFilter" {
+				return "0xf2", nil
+			}
+			return logs(msg)
+		}
+	}
+
+	e, err := createEthManager(subscriber.RPC, store.Subscription{Job: "job", Ethereum: store.EthSubscription{Strategy: EthFilter}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x5"}`)))
+
+	t.Run("installs filter", func(t *testing.T) {
+		assert.Equal(t, string(e.GetTriggerJson()), `{"jsonrpc":"2.0","id":1,"method":"eth_newFilter","params":[{"address":null,"fromBlock":"latest","toBlock":"latest","topics":[null]}]}`)
+
+		e.caller = filterCaller(6)
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0xf1"}`))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x5/0x0", "0x6/0x0"}, logPositions(t, events))
+	})
+
+	t.Run("polls filter changes", func(t *testing.T) {
+		assert.Equal(t, string(e.GetTriggerJson()), `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0xf1"]}`)
+
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x6","blockHash":"0x6","logIndex":"0x0"},{"blockNumber":"0x7","blockHash":"0x7","logIndex":"0x0"}]}`))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x7/0x0"}, logPositions(t, events))
+	})
+
+	t.Run("reinstalls dropped filter", func(t *testing.T) {
+		e.caller = filterCaller(9)
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"filter not found"}}`))
+		require.True(t, ok)
+		require.Equal(t, []string{"0x8/0x0", "0x9/0x0"}, logPositions(t, events))
+		assert.Equal(t, string(e.GetTriggerJson()), `{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0xf2"]}`)
+	})
+
+	t.Run("retries failed install", func(t *testing.T) {
+		e.caller = mockCaller(func(jsonrpcMessage) (interface{}, error) {
+			return nil, errors.New("internal error")
+		})
+		_, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"filter not found"}}`))
+		require.True(t, ok)
+		assert.Equal(t, string(e.GetTriggerJson()), `{"jsonrpc":"2.0","id":1,"method":"eth_newFilter","params":[{"address":null,"fromBlock":"latest","toBlock":"latest","topics":[null]}]}`)
+	})
+}
@@ -0,0 +1,402 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+	"strings"
+)
+
+// EthTrace is the kind of Ethereum subscriptions that trigger jobs
+// on contract calls, including internal calls between contracts.
+const EthTrace = "trace"
+
+// EthDebugTrace is the strategy of EthTrace subscriptions that trace
+// blocks with "debug_traceBlockByNumber" and the callTracer, as
+// supported by geth. By default, "trace_filter" is used, as
+// supported by OpenEthereum and Erigon.
+const EthDebugTrace = "debug"
+
+// EthTraceManager implements the subscriber.JsonManager interface. It fetches
+// the call traces of every new block, and triggers the job for each call to
+// one of the addresses, with one of the 4-byte selectors. Without addresses,
+// calls to any address match, and without selectors, any call matches.
+//
+// If EthTraceManager is using WebSocket, new blocks are received from
+// a "newHeads" subscription. If it is using RPC, the block number is
+// polled. Blocks skipped between two heads are traced as well.
+// Reorged blocks are not traced again.
+type EthTraceManager struct {
+	p         subscriber.Type
+	caller    subscriber.Caller
+	debug     bool
+	addresses []common.Address
+	selectors map[string]bool
+	// last is the last block that has been traced
+	last *uint64
+}
+
+// createEthTraceManager creates a new instance of EthTraceManager with
+// the provided connection type and store.EthSubscription config.
+func createEthTraceManager(p subscriber.Type, config store.Subscription) (*EthTraceManager, error) {
+	e := &EthTraceManager{
+		p:      p,
+		caller: subscriber.NewCaller(config.Endpoint.Url),
+	}
+
+	switch config.Ethereum.Strategy {
+	case "":
+	case EthDebugTrace:
+		e.debug = true
+	default:
+		return nil, fmt.Errorf("unknown strategy for the trace kind: %s", config.Ethereum.Strategy)
+	}
+
+	for _, a := range config.Ethereum.Addresses {
+		if !common.IsHexAddress(a) {
+			return nil, fmt.Errorf("invalid address: %s", a)
+		}
+		e.addresses = append(e.addresses, common.HexToAddress(a))
+	}
+
+	for _, s := range config.Ethereum.Selectors {
+		selector, err := hexutil.Decode(s)
+		if err != nil || len(selector) != 4 {
+			return nil, fmt.Errorf("invalid selector: %s", s)
+		}
+		if e.selectors == nil {
+			e.selectors = make(map[string]bool)
+		}
+		e.selectors[hexutil.Encode(selector)] = true
+	}
+
+	if e.addresses == nil && e.selectors == nil {
+		return nil, errors.New("the trace kind requires addresses or selectors")
+	}
+
+	return e, nil
+}
+
+// GetTriggerJson generates a JSON payload subscribing to "newHeads"
+// if EthTraceManager is using WebSocket, or requesting the current
+// block number if it is using RPC.
+func (e *EthTraceManager) GetTriggerJson() []byte {
+	msg := jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+	}
+
+	switch e.p {
+	case subscriber.WS:
+		msg.Method = "eth_subscribe"
+		msg.Params = json.RawMessage(`["newHeads"]`)
+	case subscriber.RPC:
+		msg.Method = "eth_blockNumber"
+	}
+
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// GetTestJson traces the latest block, so that a job
+// is rejected if the node does not support tracing.
+func (e *EthTraceManager) GetTestJson() []byte {
+	method, params := e.traceRequest("latest", "latest")
+	bz, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+
+	bytes, err := json.Marshal(jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  method,
+		Params:  bz,
+	})
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// ParseTestResponse returns an error if tracing failed.
+func (e *EthTraceManager) ParseTestResponse(data []byte) error {
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return fmt.Errorf("tracing returned error: %v", *msg.Error)
+	}
+	return nil
+}
+
+// ethTraceEvent is the payload sent to the
+// Chainlink node for a matching call.
+type ethTraceEvent struct {
+	BlockNumber     string `json:"blockNumber"`
+	TransactionHash string `json:"transactionHash,omitempty"`
+	TraceAddress    []int  `json:"traceAddress"`
+	CallType        string `json:"callType"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Input           string `json:"input"`
+	Value           string `json:"value"`
+}
+
+// ParseResponse parses the new head or block number, and
+// returns an event for each matching call since the last
+// block that was traced.
+func (e *EthTraceManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("failed parsing msg:", err)
+		return nil, false
+	}
+
+	var number string
+	switch e.p {
+	case subscriber.WS:
+		var res ethSubscribeResponse
+		if err := json.Unmarshal(msg.Params, &res); err != nil {
+			return nil, false
+		}
+		var head ethHeadResponse
+		if err := json.Unmarshal(res.Result, &head); err != nil {
+			return nil, false
+		}
+		number = head.Number
+	case subscriber.RPC:
+		if err := json.Unmarshal(msg.Result, &number); err != nil {
+			return nil, false
+		}
+	}
+
+	blockNumber, err := hexutil.DecodeUint64(number)
+	if err != nil {
+		log.Println("failed decoding block number:", err)
+		return nil, false
+	}
+	fromBlock := blockNumber
+	if e.last != nil {
+		if blockNumber <= *e.last {
+			return nil, true
+		}
+		fromBlock = *e.last + 1
+	}
+
+	calls, err := e.traceBlocks(fromBlock, blockNumber)
+	if err != nil {
+		log.Println("failed tracing blocks:", err)
+		return nil, false
+	}
+	e.last = &blockNumber
+
+	var events []subscriber.Event
+	for _, call := range calls {
+		if !e.matches(call) {
+			continue
+		}
+
+		event, err := json.Marshal(call)
+		if err != nil {
+			log.Println("marshal:", err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, true
+}
+
+// matches returns true if the call is to one of the
+// addresses, and starts with one of the selectors.
+func (e *EthTraceManager) matches(call ethTraceEvent) bool {
+	if e.addresses != nil && !containsAddress(e.addresses, common.HexToAddress(call.To)) {
+		return false
+	}
+	if e.selectors != nil {
+		input := strings.ToLower(call.Input)
+		if len(input) < 10 || !e.selectors[input[:10]] {
+			return false
+		}
+	}
+	return true
+}
+
+// traceRequest returns the method and params tracing the blocks provided.
+// "trace_filter" is not limited to the addresses, as a failed call to
+// another address can revert the calls to the addresses.
+func (e *EthTraceManager) traceRequest(fromBlock, toBlock string) (string, []interface{}) {
+	if e.debug {
+		return "debug_traceBlockByNumber", []interface{}{toBlock, map[string]string{"tracer": "callTracer"}}
+	}
+
+	return "trace_filter", []interface{}{map[string]string{
+		"fromBlock": fromBlock,
+		"toBlock":   toBlock,
+	}}
+}
+
+// traceBlocks returns the calls in the blocks provided, inclusive.
+func (e *EthTraceManager) traceBlocks(fromBlock, toBlock uint64) ([]ethTraceEvent, error) {
+	if !e.debug {
+		return e.traceFilter(fromBlock, toBlock)
+	}
+
+	var calls []ethTraceEvent
+	for n := fromBlock; n <= toBlock; n++ {
+		blockCalls, err := e.debugTraceBlock(n)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, blockCalls...)
+	}
+	return calls, nil
+}
+
+// parityTrace is a trace returned by "trace_filter".
+type parityTrace struct {
+	Action struct {
+		CallType string `json:"callType"`
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Input    string `json:"input"`
+		Value    string `json:"value"`
+	} `json:"action"`
+	BlockNumber     uint64 `json:"blockNumber"`
+	TransactionHash string `json:"transactionHash"`
+	TraceAddress    []int  `json:"traceAddress"`
+	Type            string `json:"type"`
+	Error           string `json:"error"`
+}
+
+func (e *EthTraceManager) traceFilter(fromBlock, toBlock uint64) ([]ethTraceEvent, error) {
+	method, params := e.traceRequest(hexutil.EncodeUint64(fromBlock), hexutil.EncodeUint64(toBlock))
+
+	var traces []parityTrace
+	if err := callJsonRpc(e.caller, method, params, &traces); err != nil {
+		return nil, err
+	}
+
+	// Traces are in call order, so a failed call is
+	// seen before the subcalls it reverted
+	failed := make(map[string]bool)
+	var calls []ethTraceEvent
+	for _, trace := range traces {
+		if trace.Error != "" || revertedByParent(failed, trace) {
+			failed[traceID(trace.TransactionHash, trace.TraceAddress)] = true
+			continue
+		}
+		if trace.Type != "call" {
+			continue
+		}
+		calls = append(calls, ethTraceEvent{
+			BlockNumber:     hexutil.EncodeUint64(trace.BlockNumber),
+			TransactionHash: trace.TransactionHash,
+			TraceAddress:    trace.TraceAddress,
+			CallType:        trace.Action.CallType,
+			From:            trace.Action.From,
+			To:              trace.Action.To,
+			Input:           trace.Action.Input,
+			Value:           trace.Action.Value,
+		})
+	}
+	return calls, nil
+}
+
+func traceID(txHash string, traceAddress []int) string {
+	return fmt.Sprint(txHash, traceAddress)
+}
+
+// revertedByParent returns true if a call that the trace is a subcall of failed.
+func revertedByParent(failed map[string]bool, trace parityTrace) bool {
+	for i := range trace.TraceAddress {
+		if failed[traceID(trace.TransactionHash, trace.TraceAddress[:i])] {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// callFrame is a call returned by the callTracer of geth.
+type callFrame struct {
+	Type  string      `json:"type"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Input string      `json:"input"`
+	Value string      `json:"value"`
+	Error string      `json:"error"`
+	Calls []callFrame `json:"calls"`
+}
+
+func (e *EthTraceManager) debugTraceBlock(number uint64) ([]ethTraceEvent, error) {
+	method, params := e.traceRequest("", hexutil.EncodeUint64(number))
+
+	var txs []struct {
+		TxHash string    `json:"txHash"`
+		Result callFrame `json:"result"`
+	}
+	if err := callJsonRpc(e.caller, method, params, &txs); err != nil {
+		return nil, err
+	}
+
+	var calls []ethTraceEvent
+	for _, tx := range txs {
+		calls = append(calls, walkCallFrame(tx.Result, []int{}, ethTraceEvent{
+			BlockNumber:     hexutil.EncodeUint64(number),
+			TransactionHash: tx.TxHash,
+		})...)
+	}
+	return calls, nil
+}
+
+// walkCallFrame returns the successful calls in the call frame and
+// its subcalls, in the order they were made. The trace address is
+// the path of indexes from the top-level call, as with "trace_filter".
+func walkCallFrame(frame callFrame, traceAddress []int, tx ethTraceEvent) []ethTraceEvent {
+	// Subcalls of a failed call were reverted as well
+	if frame.Error != "" {
+		return nil
+	}
+
+	var calls []ethTraceEvent
+	if frame.Type != "CREATE" && frame.Type != "CREATE2" && frame.Type != "SELFDESTRUCT" {
+		call := tx
+		call.TraceAddress = traceAddress
+		call.CallType = strings.ToLower(frame.Type)
+		call.From = frame.From
+		call.To = frame.To
+		call.Input = frame.Input
+		call.Value = frame.Value
+		if call.Value == "" {
+			call.Value = "0x0"
+		}
+		calls = append(calls, call)
+	}
+
+	for i, sub := range frame.Calls {
+		subAddress := append(append([]int{}, traceAddress...), i)
+		calls = append(calls, walkCallFrame(sub, subAddress, tx)...)
+	}
+	return calls
+}
@@ -0,0 +1,169 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestCreateEthTraceManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  store.EthSubscription
+		wantErr bool
+	}{
+		{"addresses", store.EthSubscription{Addresses: []string{"0x00000000000000000000000000000000000000bb"}}, false},
+		{"selectors", store.EthSubscription{Selectors: []string{"0xa9059cbb"}, Strategy: EthDebugTrace}, false},
+		{"missing addresses and selectors", store.EthSubscription{}, true},
+		{"invalid address", store.EthSubscription{Addresses: []string{"0xbb"}}, true},
+		{"invalid selector", store.EthSubscription{Selectors: []string{"0xa9059c"}}, true},
+		{"unknown strategy", store.EthSubscription{Selectors: []string{"0xa9059cbb"}, Strategy: EthHeads}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createEthTraceManager(subscriber.RPC, store.Subscription{Ethereum: tt.config})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEthTraceManager_GetTestJson(t *testing.T) {
+	e, err := createEthTraceManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{Selectors: []string{"0xa9059cbb"}}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"trace_filter","params":[{"fromBlock":"latest","toBlock":"latest"}]}`, string(e.GetTestJson()))
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, string(e.GetTriggerJson()))
+
+	e.debug = true
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"debug_traceBlockByNumber","params":["latest",{"tracer":"callTracer"}]}`, string(e.GetTestJson()))
+
+	require.NoError(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[]}`)))
+	require.Error(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method debug_traceBlockByNumber does not exist/is not available"}}`)))
+}
+
+func TestEthTraceManager_ParseResponse(t *testing.T) {
+	traceFilter, err := ioutil.ReadFile(path.Join("testdata", "eth_trace_filter.json"))
+	require.NoError(t, err)
+	debugTrace, err := ioutil.ReadFile(path.Join("testdata", "eth_debug_trace_block.json"))
+	require.NoError(t, err)
+
+	var traced []string
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "trace_filter":
+			filter := params[0].(map[string]interface{})
+			traced = append(traced, filter["fromBlock"].(string)+"-"+filter["toBlock"].(string))
+			if filter["toBlock"] != "0x10" {
+				return []interface{}{}, nil
+			}
+			return json.RawMessage(traceFilter), nil
+		case "debug_traceBlockByNumber":
+			traced = append(traced, params[0].(string))
+			if params[0] != "0x10" {
+				return []interface{}{}, nil
+			}
+			return json.RawMessage(debugTrace), nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	transfer := ethTraceEvent{
+		BlockNumber:     "0x10",
+		TransactionHash: "0x00000000000000000000000000000000000000000000000000000000000000f1",
+		TraceAddress:    []int{0},
+		CallType:        "call",
+		From:            "0x00000000000000000000000000000000000000aa",
+		To:              "0x00000000000000000000000000000000000000bb",
+		Input:           "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000064",
+		Value:           "0x0",
+	}
+	balanceOf := ethTraceEvent{
+		BlockNumber:     "0x10",
+		TransactionHash: "0x00000000000000000000000000000000000000000000000000000000000000f2",
+		TraceAddress:    []int{},
+		CallType:        "staticcall",
+		From:            "0x0000000000000000000000000000000000000001",
+		To:              "0x00000000000000000000000000000000000000bb",
+		Input:           "0x70a082310000000000000000000000000000000000000000000000000000000000000001",
+		Value:           "0x0",
+	}
+
+	tests := []struct {
+		name       string
+		config     store.EthSubscription
+		want       []ethTraceEvent
+		wantTraced []string
+	}{
+		{
+			"trace_filter with address",
+			store.EthSubscription{Addresses: []string{"0x00000000000000000000000000000000000000bb"}},
+			[]ethTraceEvent{transfer, balanceOf},
+			[]string{"0xe-0xe", "0xf-0x10"},
+		},
+		{
+			"trace_filter with address and selector",
+			store.EthSubscription{Addresses: []string{"0x00000000000000000000000000000000000000bb"}, Selectors: []string{"0xa9059cbb"}},
+			[]ethTraceEvent{transfer},
+			[]string{"0xe-0xe", "0xf-0x10"},
+		},
+		{
+			"debug_traceBlockByNumber with address",
+			store.EthSubscription{Addresses: []string{"0x00000000000000000000000000000000000000bb"}, Strategy: EthDebugTrace},
+			[]ethTraceEvent{transfer, balanceOf},
+			[]string{"0xe", "0xf", "0x10"},
+		},
+		{
+			"debug_traceBlockByNumber with selector",
+			store.EthSubscription{Selectors: []string{"0xA9059CBB"}, Strategy: EthDebugTrace},
+			[]ethTraceEvent{transfer},
+			[]string{"0xe", "0xf", "0x10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traced = nil
+			e, err := createEthTraceManager(subscriber.RPC, store.Subscription{Ethereum: tt.config})
+			require.NoError(t, err)
+			e.caller = caller
+
+			var got []ethTraceEvent
+			for _, block := range []string{"0xe", "0xe", "0x10", "0x10"} {
+				events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + block + `"}`))
+				require.True(t, ok)
+				for _, event := range events {
+					var call ethTraceEvent
+					require.NoError(t, json.Unmarshal(event, &call))
+					got = append(got, call)
+				}
+			}
+
+			assert.Equal(t, tt.want, got)
+			// Blocks are traced once, including skipped blocks
+			assert.Equal(t, tt.wantTraced, traced)
+		})
+	}
+
+	t.Run("retries failed blocks", func(t *testing.T) {
+		e, err := createEthTraceManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Selectors: []string{"0xa9059cbb"}}})
+		require.NoError(t, err)
+		e.caller = mockCaller(func(jsonrpcMessage) (interface{}, error) {
+			return nil, errors.New("the method trace_filter does not exist/is not available")
+		})
+
+		_, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"number":"0x10","hash":"0x10","parentHash":"0x0f"}}}`))
+		require.False(t, ok)
+		assert.Nil(t, e.last)
+	})
+}
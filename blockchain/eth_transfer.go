@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"math/big"
+)
+
+// EthErc20 is the kind of Ethereum subscriptions that
+// are triggered by ERC-20 token transfers.
+const EthErc20 = "erc20"
+
+// EthErc721 is the kind of Ethereum subscriptions that
+// are triggered by ERC-721 token transfers.
+const EthErc721 = "erc721"
+
+// ERC-20 and ERC-721 Transfer events have the same signature, and are
+// told apart by the token ID being indexed, so in the topics.
+var (
+	erc20TransferEvent, _  = parseEventABI("Transfer(address indexed from, address indexed to, uint256 value)")
+	erc721TransferEvent, _ = parseEventABI("Transfer(address indexed from, address indexed to, uint256 indexed tokenId)")
+)
+
+// transferPreset is the log filter of the EthErc20 and EthErc721 kinds,
+// built from the token, sender and recipient of the subscription.
+type transferPreset struct {
+	event     *abi.Event
+	addresses []common.Address
+	topics    [][]common.Hash
+	minAmount *big.Int
+}
+
+// newTransferPreset creates the transferPreset of a subscription of the
+// EthErc20 or EthErc721 kind. Transfers can be filtered on the token
+// contract, on any of the senders and on any of the recipients, and
+// ERC-20 transfers on a minimum amount.
+func newTransferPreset(config store.EthSubscription) (*transferPreset, error) {
+	t := &transferPreset{event: erc20TransferEvent}
+	if config.Kind == EthErc721 {
+		t.event = erc721TransferEvent
+	}
+
+	if config.Token != "" {
+		if !common.IsHexAddress(config.Token) {
+			return nil, fmt.Errorf("invalid token: %s", config.Token)
+		}
+		t.addresses = []common.Address{common.HexToAddress(config.Token)}
+	}
+
+	from, err := addressTopics(config.TransferFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %v", err)
+	}
+	to, err := addressTopics(config.TransferTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to address: %v", err)
+	}
+	t.topics = [][]common.Hash{{t.event.ID()}, from, to}
+
+	if config.MinAmount != "" {
+		if config.Kind != EthErc20 {
+			return nil, errors.New("minAmount is only supported by the erc20 kind")
+		}
+		minAmount, ok := new(big.Int).SetString(config.MinAmount, 10)
+		if !ok || minAmount.Sign() < 0 {
+			return nil, fmt.Errorf("invalid minimum amount: %s", config.MinAmount)
+		}
+		t.minAmount = minAmount
+	}
+
+	return t, nil
+}
+
+// addressTopics returns the addresses as topics of indexed arguments.
+// Returns nil if there are no addresses, which matches any topic.
+func addressTopics(addresses []string) ([]common.Hash, error) {
+	var topics []common.Hash
+	for _, a := range addresses {
+		if !common.IsHexAddress(a) {
+			return nil, errors.New(a)
+		}
+		topics = append(topics, common.HexToAddress(a).Hash())
+	}
+	return topics, nil
+}
+
+// matches returns true if the log is a transfer of the right token
+// standard, and if it transfers at least the minimum amount.
+func (t *transferPreset) matches(evt ethLogResponse) bool {
+	indexed := len(t.event.Inputs) - len(t.event.Inputs.NonIndexed())
+	if len(evt.Topics) != indexed+1 {
+		return false
+	}
+
+	if t.minAmount == nil {
+		return true
+	}
+	data, err := hexutil.Decode(evt.Data)
+	if err != nil || len(data) != common.HashLength {
+		return false
+	}
+	return new(big.Int).SetBytes(data).Cmp(t.minAmount) >= 0
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func TestNewTransferPreset(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  store.EthSubscription
+		wantErr bool
+	}{
+		{"erc20", store.EthSubscription{Kind: EthErc20, Token: "0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484", MinAmount: "1000"}, false},
+		{"erc721", store.EthSubscription{Kind: EthErc721, TransferTo: []string{"0x00000000000000000000000000000000000000bb"}}, false},
+		{"invalid token", store.EthSubscription{Kind: EthErc20, Token: "0xbb"}, true},
+		{"invalid from", store.EthSubscription{Kind: EthErc20, TransferFrom: []string{"foo"}}, true},
+		{"invalid to", store.EthSubscription{Kind: EthErc721, TransferTo: []string{"0x00000000000000000000000000000000000000bb", "0xbb"}}, true},
+		{"invalid minimum amount", store.EthSubscription{Kind: EthErc20, MinAmount: "0x10"}, true},
+		{"negative minimum amount", store.EthSubscription{Kind: EthErc20, MinAmount: "-1"}, true},
+		{"minimum amount of erc721", store.EthSubscription{Kind: EthErc721, MinAmount: "1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTransferPreset(tt.config)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEthManager_Transfer(t *testing.T) {
+	token := common.HexToAddress("0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+	erc20 := func(logIndex string, amount int64) ethLogResponse {
+		return ethLogResponse{
+			Address:     token.Hex(),
+			BlockNumber: "0x1",
+			LogIndex:    logIndex,
+			Topics:      []string{transferTopic, alice.Hash().Hex(), bob.Hash().Hex()},
+			Data:        common.BigToHash(big.NewInt(amount)).Hex(),
+		}
+	}
+	erc721 := func(logIndex string, tokenID int64) ethLogResponse {
+		return ethLogResponse{
+			Address:     token.Hex(),
+			BlockNumber: "0x1",
+			LogIndex:    logIndex,
+			Topics:      []string{transferTopic, alice.Hash().Hex(), bob.Hash().Hex(), common.BigToHash(big.NewInt(tokenID)).Hex()},
+			Data:        "0x",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		config      store.EthSubscription
+		wantTopics  [][]common.Hash
+		wantPos     []string
+		wantDecoded map[string]interface{}
+	}{
+		{
+			"erc20",
+			store.EthSubscription{Kind: EthErc20, Token: token.Hex(), TransferTo: []string{bob.Hex()}, MinAmount: "100"},
+			[][]common.Hash{{common.HexToHash(transferTopic)}, nil, {bob.Hash()}},
+			[]string{"0x1/0x1"},
+			map[string]interface{}{"from": alice.Hex(), "to": bob.Hex(), "value": "100"},
+		},
+		{
+			"erc721",
+			store.EthSubscription{Kind: EthErc721, Token: token.Hex(), TransferFrom: []string{alice.Hex()}},
+			[][]common.Hash{{common.HexToHash(transferTopic)}, {alice.Hash()}, nil},
+			[]string{"0x1/0x2"},
+			map[string]interface{}{"from": alice.Hex(), "to": bob.Hex(), "tokenId": "7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := createEthManager(subscriber.RPC, store.Subscription{Ethereum: tt.config}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, []common.Address{token}, e.fq.Addresses)
+			assert.Equal(t, tt.wantTopics, e.fq.Topics)
+
+			events := e.logsToEvents([]ethLogResponse{erc20("0x0", 99), erc20("0x1", 100), erc721("0x2", 7)})
+			require.Equal(t, tt.wantPos, logPositions(t, events))
+
+			var payload ethLogEvent
+			require.NoError(t, json.Unmarshal(events[0], &payload))
+			assert.Equal(t, tt.wantDecoded, payload.Decoded)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"math/big"
+)
+
+// txSuccess is the receipt status of a successful transaction.
+const txSuccess = "0x1"
+
+// ethTxDetails are the fields of the transaction and receipt of a log
+// that are attached to the event sent to the Chainlink node.
+type ethTxDetails struct {
+	Status string `json:"status"`
+	From   string `json:"from"`
+	Value  string `json:"value"`
+}
+
+// txFilter filters logs on the transaction that emitted them.
+// A nil txFilter means that transactions are not fetched.
+type txFilter struct {
+	requireSuccess bool
+	from           map[common.Address]bool
+	minValue       *big.Int
+}
+
+// newTxFilter creates a txFilter from the subscription config, or
+// returns nil if the subscription does not need transaction details.
+func newTxFilter(config store.EthSubscription) (*txFilter, error) {
+	if !config.Receipts && !config.RequireSuccess && len(config.TxFrom) == 0 && config.MinValue == "" {
+		return nil, nil
+	}
+
+	f := &txFilter{requireSuccess: config.RequireSuccess}
+
+	for _, a := range config.TxFrom {
+		if !common.IsHexAddress(a) {
+			return nil, fmt.Errorf("invalid transaction sender: %s", a)
+		}
+		if f.from == nil {
+			f.from = make(map[common.Address]bool)
+		}
+		f.from[common.HexToAddress(a)] = true
+	}
+
+	if config.MinValue != "" {
+		var ok bool
+		f.minValue, ok = new(big.Int).SetString(config.MinValue, 10)
+		if !ok || f.minValue.Sign() < 0 {
+			return nil, fmt.Errorf("invalid minimum value: %s", config.MinValue)
+		}
+	}
+
+	return f, nil
+}
+
+// matches returns true if the transaction passes the filter.
+func (f *txFilter) matches(tx ethTxDetails) bool {
+	if f.requireSuccess && tx.Status != txSuccess {
+		return false
+	}
+	if f.from != nil && !f.from[common.HexToAddress(tx.From)] {
+		return false
+	}
+	if f.minValue != nil {
+		value, err := hexutil.DecodeBig(tx.Value)
+		if err != nil || value.Cmp(f.minValue) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// getTxDetails fetches the receipt and transaction with the hash provided.
+// Receipts from before the Byzantium fork have no status, and are reported
+// with an empty status.
+func (e EthManager) getTxDetails(txHash string) (ethTxDetails, error) {
+	var receipt *struct {
+		Status string `json:"status"`
+	}
+	if err := callJsonRpc(e.caller, "eth_getTransactionReceipt", []interface{}{txHash}, &receipt); err != nil {
+		return ethTxDetails{}, err
+	}
+	if receipt == nil {
+		return ethTxDetails{}, fmt.Errorf("receipt of tx %s not found", txHash)
+	}
+
+	var tx *struct {
+		From  string `json:"from"`
+		Value string `json:"value"`
+	}
+	if err := callJsonRpc(e.caller, "eth_getTransactionByHash", []interface{}{txHash}, &tx); err != nil {
+		return ethTxDetails{}, err
+	}
+	if tx == nil {
+		return ethTxDetails{}, fmt.Errorf("tx %s not found", txHash)
+	}
+
+	return ethTxDetails{
+		Status: receipt.Status,
+		From:   tx.From,
+		Value:  tx.Value,
+	}, nil
+}
+
+// txDetailsCache fetches the details of each transaction once,
+// as a transaction can emit several matching logs.
+type txDetailsCache map[string]ethTxDetails
+
+func (c txDetailsCache) get(e EthManager, txHash string) (ethTxDetails, error) {
+	if txHash == "" {
+		return ethTxDetails{}, errors.New("log has no transaction hash")
+	}
+	if tx, ok := c[txHash]; ok {
+		return tx, nil
+	}
+
+	tx, err := e.getTxDetails(txHash)
+	if err != nil {
+		return ethTxDetails{}, err
+	}
+	c[txHash] = tx
+	return tx, nil
+}
@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_newTxFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  store.EthSubscription
+		wantNil bool
+		wantErr bool
+	}{
+		{"disabled", store.EthSubscription{}, true, false},
+		{"receipts only", store.EthSubscription{Receipts: true}, false, false},
+		{"all filters", store.EthSubscription{RequireSuccess: true, TxFrom: []string{"0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484"}, MinValue: "1000"}, false, false},
+		{"invalid sender", store.EthSubscription{TxFrom: []string{"0x123"}}, false, true},
+		{"invalid minimum value", store.EthSubscription{MinValue: "0x10"}, false, true},
+		{"negative minimum value", store.EthSubscription{MinValue: "-1"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newTxFilter(tt.config)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, f == nil)
+		})
+	}
+}
+
+func Test_txFilter_matches(t *testing.T) {
+	f, err := newTxFilter(store.EthSubscription{
+		RequireSuccess: true,
+		TxFrom:         []string{"0x049Bd8C3adC3fE7d3Fc2a44541d955A537c2A484"},
+		MinValue:       "256",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		tx   ethTxDetails
+		want bool
+	}{
+		{"matches", ethTxDetails{Status: "0x1", From: "0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484", Value: "0x100"}, true},
+		{"failed", ethTxDetails{Status: "0x0", From: "0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484", Value: "0x100"}, false},
+		{"without status", ethTxDetails{From: "0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484", Value: "0x100"}, false},
+		{"other sender", ethTxDetails{Status: "0x1", From: "0x0000000000000000000000000000000000000001", Value: "0x100"}, false},
+		{"value too low", ethTxDetails{Status: "0x1", From: "0x049bd8c3adc3fe7d3fc2a44541d955a537c2a484", Value: "0xff"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, f.matches(tt.tx))
+		})
+	}
+}
+
+func TestEthManager_TxFilter(t *testing.T) {
+	type tx struct {
+		status string
+		value  string
+	}
+	txs := map[string]tx{
+		"0x01": {"0x1", "0x10"},
+		"0x02": {"0x0", "0x10"},
+		"0x03": {"0x1", "0x0"},
+	}
+	calls := map[string]int{}
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []string
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		calls[msg.Method+" "+params[0]]++
+
+		tx, ok := txs[params[0]]
+		if !ok {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "eth_getTransactionReceipt":
+			return map[string]string{"status": tx.status}, nil
+		case "eth_getTransactionByHash":
+			return map[string]string{"from": "0x0000000000000000000000000000000000000001", "value": tx.value}, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	f, err := newTxFilter(store.EthSubscription{RequireSuccess: true, MinValue: "1"})
+	require.NoError(t, err)
+	e := EthManager{fq: &filterQuery{}, p: subscriber.RPC, caller: caller, queue: &confirmationQueue{}, txFilter: f}
+
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[` +
+		`{"blockNumber":"0x1","logIndex":"0x0","transactionHash":"0x01"},` +
+		`{"blockNumber":"0x1","logIndex":"0x1","transactionHash":"0x01"},` +
+		`{"blockNumber":"0x1","logIndex":"0x2","transactionHash":"0x02"},` +
+		`{"blockNumber":"0x1","logIndex":"0x3","transactionHash":"0x03"},` +
+		`{"blockNumber":"0x1","logIndex":"0x4","transactionHash":"0x04"}]}`))
+	require.True(t, ok)
+	require.Equal(t, []string{"0x1/0x0", "0x1/0x1"}, logPositions(t, events))
+
+	var got ethLogEvent
+	require.NoError(t, json.Unmarshal(events[0], &got))
+	assert.Equal(t, &ethTxDetails{Status: "0x1", From: "0x0000000000000000000000000000000000000001", Value: "0x10"}, got.Transaction)
+
+	// Transactions with several logs are only fetched once
+	assert.Equal(t, 1, calls["eth_getTransactionReceipt 0x01"])
+	assert.Equal(t, 1, calls["eth_getTransactionByHash 0x01"])
+
+	t.Run("forwards removed logs", func(t *testing.T) {
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x1","logIndex":"0x4","transactionHash":"0x04","removed":true}]}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+		assert.NotContains(t, string(events[0]), "transaction\"")
+	})
+}
+
+func TestEthManager_TxFilter_Retry(t *testing.T) {
+	failing := true
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		if failing {
+			return nil, errors.New("unavailable")
+		}
+		switch msg.Method {
+		case "eth_getTransactionReceipt":
+			return map[string]string{"status": "0x1"}, nil
+		case "eth_getTransactionByHash":
+			return map[string]string{"from": "0x0000000000000000000000000000000000000001", "value": "0x10"}, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	f, err := newTxFilter(store.EthSubscription{RequireSuccess: true})
+	require.NoError(t, err)
+	db := &mockStateStorer{}
+	e := EthManager{
+		fq:       &filterQuery{},
+		p:        subscriber.RPC,
+		caller:   caller,
+		queue:    &confirmationQueue{},
+		retry:    &retryQueue{},
+		cursor:   newCursorTracker(db, "test"),
+		txFilter: f,
+	}
+
+	// The log is kept, and the cursor is not moved past it
+	events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"blockNumber":"0x5","logIndex":"0x0","transactionHash":"0x01"}]}`))
+	require.True(t, ok)
+	assert.Empty(t, events)
+	assert.Equal(t, "0x6", e.fq.FromBlock)
+	require.NotNil(t, db.cursor)
+	assert.Equal(t, uint64(4), db.cursor.BlockNumber)
+
+	failing = false
+	events, ok = e.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":[]}`))
+	require.True(t, ok)
+	assert.Equal(t, []string{"0x5/0x0"}, logPositions(t, events))
+	assert.Equal(t, uint64(5), db.cursor.BlockNumber)
+}
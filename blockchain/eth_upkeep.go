@@ -0,0 +1,227 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+)
+
+// EthUpkeep is the kind of Ethereum subscriptions that trigger jobs when
+// "checkUpkeep(bytes)" of a Keeper compatible contract returns true.
+const EthUpkeep = "upkeep"
+
+var checkUpkeepMethod, _ = parseFunctionABI("checkUpkeep(bytes checkData) returns (bool upkeepNeeded, bytes performData)")
+
+// EthUpkeepManager implements the subscriber.JsonManager interface. It calls
+// "checkUpkeep(bytes)" for every new block, and triggers the job with the
+// returned performData when upkeep is needed. After triggering, the job is
+// not triggered again until the cooldown number of blocks have passed.
+//
+// If EthUpkeepManager is using WebSocket, new blocks are received
+// from a "newHeads" subscription. If it is using RPC, the block
+// number is polled, and each block is only checked once.
+type EthUpkeepManager struct {
+	p        subscriber.Type
+	address  common.Address
+	data     []byte
+	caller   subscriber.Caller
+	cooldown uint64
+	checked  uint64
+	// triggered is the block the job was last triggered at
+	triggered *uint64
+}
+
+// createEthUpkeepManager creates a new instance of EthUpkeepManager with the
+// provided connection type and store.EthSubscription config. The checkData
+// is the first of the subscription args, if any.
+func createEthUpkeepManager(p subscriber.Type, config store.Subscription) (*EthUpkeepManager, error) {
+	if len(config.Ethereum.Addresses) != 1 {
+		return nil, errors.New("the upkeep kind requires exactly 1 address")
+	}
+	if len(config.Ethereum.Args) > 1 {
+		return nil, errors.New("the upkeep kind takes checkData as the only arg")
+	}
+
+	var checkData []byte
+	if len(config.Ethereum.Args) == 1 {
+		var err error
+		checkData, err = hexutil.Decode(config.Ethereum.Args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkData: %v", err)
+		}
+	}
+
+	packed, err := checkUpkeepMethod.Inputs.Pack(checkData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthUpkeepManager{
+		p:        p,
+		address:  common.HexToAddress(config.Ethereum.Addresses[0]),
+		data:     append(checkUpkeepMethod.ID(), packed...),
+		caller:   subscriber.NewCaller(config.Endpoint.Url),
+		cooldown: config.Ethereum.Cooldown,
+	}, nil
+}
+
+// GetTriggerJson generates a JSON payload subscribing to "newHeads"
+// if EthUpkeepManager is using WebSocket, or requesting the current
+// block number if it is using RPC.
+func (e *EthUpkeepManager) GetTriggerJson() []byte {
+	msg := jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+	}
+
+	switch e.p {
+	case subscriber.WS:
+		msg.Method = "eth_subscribe"
+		msg.Params = json.RawMessage(`["newHeads"]`)
+	case subscriber.RPC:
+		msg.Method = "eth_blockNumber"
+	}
+
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// GetTestJson calls "checkUpkeep(bytes)" at the latest block,
+// so that a job for an incompatible contract is rejected.
+func (e *EthUpkeepManager) GetTestJson() []byte {
+	params, err := json.Marshal(e.callParams("latest"))
+	if err != nil {
+		return nil
+	}
+
+	bytes, err := json.Marshal(jsonrpcMessage{
+		Version: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "eth_call",
+		Params:  params,
+	})
+	if err != nil {
+		return nil
+	}
+
+	return bytes
+}
+
+// ParseTestResponse returns an error if the call failed,
+// or did not return the values of "checkUpkeep(bytes)".
+func (e *EthUpkeepManager) ParseTestResponse(data []byte) error {
+	result, err := parseCallResult(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = checkUpkeepMethod.Outputs.UnpackValues(result)
+	return err
+}
+
+// ethUpkeepEvent is the payload sent to
+// the Chainlink node when upkeep is needed.
+type ethUpkeepEvent struct {
+	Address     string        `json:"address"`
+	BlockNumber string        `json:"blockNumber"`
+	PerformData hexutil.Bytes `json:"performData"`
+}
+
+// ParseResponse parses the new head or block number, and returns
+// an event if upkeep is needed at that block.
+func (e *EthUpkeepManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	var msg jsonrpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Println("failed parsing msg:", err)
+		return nil, false
+	}
+
+	var number string
+	switch e.p {
+	case subscriber.WS:
+		var res ethSubscribeResponse
+		if err := json.Unmarshal(msg.Params, &res); err != nil {
+			return nil, false
+		}
+		var head ethHeadResponse
+		if err := json.Unmarshal(res.Result, &head); err != nil {
+			return nil, false
+		}
+		number = head.Number
+	case subscriber.RPC:
+		if err := json.Unmarshal(msg.Result, &number); err != nil {
+			return nil, false
+		}
+	}
+
+	blockNumber, err := hexutil.DecodeUint64(number)
+	if err != nil {
+		log.Println("failed decoding block number:", err)
+		return nil, false
+	}
+
+	event, err := e.checkUpkeep(blockNumber)
+	if err != nil {
+		log.Println("failed checking upkeep:", err)
+		return nil, false
+	}
+	if event == nil {
+		return nil, true
+	}
+
+	return []subscriber.Event{event}, true
+}
+
+// checkUpkeep calls "checkUpkeep(bytes)" at the block provided, and returns
+// an event if upkeep is needed. Blocks that have been checked already, or
+// that are in the cooldown period, are not checked.
+func (e *EthUpkeepManager) checkUpkeep(blockNumber uint64) (subscriber.Event, error) {
+	if blockNumber <= e.checked {
+		return nil, nil
+	}
+	if e.triggered != nil && blockNumber < *e.triggered+e.cooldown {
+		return nil, nil
+	}
+
+	var result hexutil.Bytes
+	err := callJsonRpc(e.caller, "eth_call", e.callParams(hexutil.EncodeUint64(blockNumber)), &result)
+	if err != nil {
+		return nil, err
+	}
+	e.checked = blockNumber
+
+	values, err := checkUpkeepMethod.Outputs.UnpackValues(result)
+	if err != nil {
+		return nil, err
+	}
+	if needed, _ := values[0].(bool); !needed {
+		return nil, nil
+	}
+
+	e.triggered = &blockNumber
+	performData, _ := values[1].([]byte)
+	return json.Marshal(ethUpkeepEvent{
+		Address:     e.address.Hex(),
+		BlockNumber: hexutil.EncodeUint64(blockNumber),
+		PerformData: performData,
+	})
+}
+
+func (e *EthUpkeepManager) callParams(block string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"to":   e.address,
+			"data": hexutil.Bytes(e.data),
+		},
+		block,
+	}
+}
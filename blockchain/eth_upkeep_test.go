@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func upkeepResult(t *testing.T, needed bool, performData []byte) hexutil.Bytes {
+	result, err := checkUpkeepMethod.Outputs.Pack(needed, performData)
+	require.NoError(t, err)
+	return result
+}
+
+func TestCreateEthUpkeepManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  store.EthSubscription
+		wantErr bool
+	}{
+		{"without checkData", store.EthSubscription{Addresses: []string{"0x1"}}, false},
+		{"with checkData", store.EthSubscription{Addresses: []string{"0x1"}, Args: []string{"0xabcd"}}, false},
+		{"missing address", store.EthSubscription{}, true},
+		{"invalid checkData", store.EthSubscription{Addresses: []string{"0x1"}, Args: []string{"abcd"}}, true},
+		{"too many args", store.EthSubscription{Addresses: []string{"0x1"}, Args: []string{"0x01", "0x02"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createEthUpkeepManager(subscriber.RPC, store.Subscription{Ethereum: tt.config})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEthUpkeepManager_GetTriggerJson(t *testing.T) {
+	config := store.Subscription{Ethereum: store.EthSubscription{Addresses: []string{"0x1"}, Args: []string{"0xabcd"}}}
+
+	e, err := createEthUpkeepManager(subscriber.WS, config)
+	require.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`, string(e.GetTriggerJson()))
+
+	e, err = createEthUpkeepManager(subscriber.RPC, config)
+	require.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`, string(e.GetTriggerJson()))
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"data":"0x6e04ff0d00000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000002abcd000000000000000000000000000000000000000000000000000000000000","to":"0x0000000000000000000000000000000000000001"},"latest"]}`, string(e.GetTestJson()))
+
+	require.NoError(t, e.ParseTestResponse([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"%s"}`, upkeepResult(t, false, nil)))))
+	require.Error(t, e.ParseTestResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x"}`)))
+}
+
+func TestEthUpkeepManager_ParseResponse(t *testing.T) {
+	var calls []string
+	needed := map[string]bool{"0x2": true, "0x3": true, "0x5": true, "0x6": true}
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		if msg.Method != "eth_call" {
+			return nil, errors.New("unexpected method")
+		}
+
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		block := params[1].(string)
+		calls = append(calls, block)
+		if block == "0x8" {
+			return nil, errors.New("execution reverted")
+		}
+		return upkeepResult(t, needed[block], []byte(block)), nil
+	})
+
+	e, err := createEthUpkeepManager(subscriber.RPC, store.Subscription{Ethereum: store.EthSubscription{Addresses: []string{"0x1"}, Cooldown: 3}})
+	require.NoError(t, err)
+	e.caller = caller
+
+	var triggered []string
+	for _, block := range []string{"0x1", "0x2", "0x2", "0x3", "0x4", "0x5", "0x6", "0x7", "0x8"} {
+		events, ok := e.ParseResponse([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"%s"}`, block)))
+		require.Equal(t, block != "0x8", ok)
+
+		for _, event := range events {
+			var got ethUpkeepEvent
+			require.NoError(t, json.Unmarshal(event, &got))
+			assert.Equal(t, "0x0000000000000000000000000000000000000001", got.Address)
+			assert.Equal(t, got.BlockNumber, string(got.PerformData))
+			triggered = append(triggered, got.BlockNumber)
+		}
+	}
+
+	// Blocks are checked once, and not during the cooldown
+	assert.Equal(t, []string{"0x1", "0x2", "0x5", "0x8"}, calls)
+	assert.Equal(t, []string{"0x2", "0x5"}, triggered)
+
+	t.Run("checks new heads", func(t *testing.T) {
+		e, err := createEthUpkeepManager(subscriber.WS, store.Subscription{Ethereum: store.EthSubscription{Addresses: []string{"0x1"}}})
+		require.NoError(t, err)
+		e.caller = caller
+
+		events, ok := e.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"test","result":{"number":"0x3","hash":"0x03","parentHash":"0x02"}}}`))
+		require.True(t, ok)
+		require.Len(t, events, 1)
+	})
+}
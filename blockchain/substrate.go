@@ -19,25 +19,73 @@ type substrateFilter struct {
 	Address []types.Address
 }
 
+// SubstrateManager implements the subscriber.JsonManager interface. If it
+// is using WebSocket, it subscribes to changes of the System.Events storage.
+// If it is using RPC, it polls the latest header, and reads the events of
+// every block since the last poll. In finalized-only mode, it follows the
+// finalized heads instead, reading the events of every finalized block.
+// Events are decoded with the metadata of the runtime version at their
+// block, which is refreshed on upgrades. The last block processed is
+// stored, and the blocks missed while disconnected are read on reconnect.
 type SubstrateManager struct {
 	filter substrateFilter
-	meta   *types.Metadata
-	key    types.StorageKey
+	// pallet matches the events of the configured pallet,
+	// or is nil to match Chainlink OracleRequests
+	pallet *substratePalletFilter
+	// prefixes are the network prefixes of the SS58 account IDs
+	// of the filter, checked against the chain when testing
+	prefixes map[string]uint16
+	// state is whether the subscription is paused, and the requests
+	// killed, as controlled by the events of the Chainlink pallet
+	state *store.SubscriptionState
+	db    StateStorer
+	// requests are the IDs of the most recent requests emitted
+	requests []string
+	meta     *types.Metadata
+	key      types.StorageKey
+	p        subscriber.Type
+	caller   subscriber.Caller
+	// finalized only reads the events of finalized blocks
+	finalized bool
+	// next is the next block to read the events of,
+	// when using RPC or in finalized-only mode
+	next *uint64
+	// cursor is the last block processed, used to
+	// read the blocks missed while disconnected
+	cursor *cursorTracker
+	// specVersion is the runtime version meta is valid for,
+	// or nil if meta has not been checked against a block yet
+	specVersion *uint32
+	// specRange is the range of blocks known to have specVersion,
+	// or nil if no block number is known to have it
+	specRange *substrateSpecRange
 }
 
-func createSubstrateManager(t subscriber.Type, conf store.Subscription) (*SubstrateManager, error) {
-	if t != subscriber.WS {
-		return nil, errors.New("only WS connections are allowed for Substrate")
+func createSubstrateManager(t subscriber.Type, conf store.Subscription, db StateStorer) (*SubstrateManager, error) {
+	if t != subscriber.WS && t != subscriber.RPC {
+		return nil, errors.New("only WS and RPC connections are allowed for Substrate")
 	}
 
 	var addresses []types.Address
+	prefixes := make(map[string]uint16)
 	for _, id := range conf.Substrate.AccountIds {
-		address, err := types.NewAddressFromHexAccountID(id)
+		accountID, prefix, err := parseSubstrateAccountID(id)
 		if err != nil {
-			fmt.Println(err)
-			continue
+			return nil, fmt.Errorf("invalid account ID %s: %v", id, err)
+		}
+		addresses = append(addresses, types.NewAddressFromAccountID(accountID[:]))
+		if prefix != nil {
+			prefixes[id] = *prefix
+		}
+	}
+
+	var pallet *substratePalletFilter
+	if conf.Substrate.Pallet != "" {
+		var err error
+		pallet, err = newSubstratePalletFilter(conf.Substrate)
+		if err != nil {
+			return nil, err
 		}
-		addresses = append(addresses, address)
 	}
 
 	return &SubstrateManager{
@@ -45,9 +93,21 @@ func createSubstrateManager(t subscriber.Type, conf store.Subscription) (*Substr
 			JobID:   types.NewText(conf.Job),
 			Address: addresses,
 		},
+		pallet:    pallet,
+		prefixes:  prefixes,
+		finalized: conf.Substrate.Finalized,
+		state:     loadSubstrateState(db, conf.Job),
+		db:        db,
+		cursor:    newCursorTracker(db, conf.Job),
+		p:         t,
+		caller:    subscriber.NewCaller(conf.Endpoint.Url),
 	}, nil
 }
 
+// GetTriggerJson generates a JSON payload subscribing to the System.Events
+// storage if SubstrateManager is using WebSocket, or requesting the latest
+// header if it is using RPC. In finalized-only mode, it subscribes to the
+// finalized heads, or requests the latest finalized head.
 func (sm *SubstrateManager) GetTriggerJson() []byte {
 	if sm.meta == nil {
 		return nil
@@ -68,6 +128,21 @@ func (sm *SubstrateManager) GetTriggerJson() []byte {
 		Method:  "state_subscribeStorage",
 	}
 
+	if sm.p == subscriber.RPC {
+		msg.Method = "chain_getHeader"
+		if sm.finalized {
+			msg.Method = "chain_getFinalizedHead"
+		}
+		data, _ := json.Marshal(msg)
+		return data
+	}
+
+	if sm.finalized {
+		msg.Method = "chain_subscribeFinalizedHeads"
+		data, _ := json.Marshal(msg)
+		return data
+	}
+
 	keys := [][]string{{sm.key.Hex()}}
 	params, err := json.Marshal(keys)
 	if err != nil {
@@ -164,7 +239,15 @@ type substrateSubscribeResponse struct {
 	Result       json.RawMessage `json:"result"`
 }
 
+// ParseResponse returns the events of the blocks read for the response.
+// The events of a request killed, or of a subscription paused, in a later
+// block read with it are dropped, as they have not been handed off yet.
 func (sm *SubstrateManager) ParseResponse(data []byte) ([]subscriber.Event, bool) {
+	events, ok := sm.parseResponse(data)
+	return sm.dropCancelled(events), ok
+}
+
+func (sm *SubstrateManager) parseResponse(data []byte) ([]subscriber.Event, bool) {
 	var msg jsonrpcMessage
 	err := json.Unmarshal(data, &msg)
 	if err != nil {
@@ -172,6 +255,10 @@ func (sm *SubstrateManager) ParseResponse(data []byte) ([]subscriber.Event, bool
 		return nil, false
 	}
 
+	if sm.p == subscriber.RPC {
+		return sm.pollBlocks(msg)
+	}
+
 	var subRes substrateSubscribeResponse
 	err = json.Unmarshal(msg.Params, &subRes)
 	if err != nil {
@@ -179,6 +266,15 @@ func (sm *SubstrateManager) ParseResponse(data []byte) ([]subscriber.Event, bool
 		return nil, false
 	}
 
+	if sm.finalized {
+		var header types.Header
+		if err = json.Unmarshal(subRes.Result, &header); err != nil {
+			fmt.Println("Failed parsing finalized header:", err)
+			return nil, false
+		}
+		return sm.readBlocks(uint64(header.Number)), true
+	}
+
 	var changes types.StorageChangeSet
 	err = json.Unmarshal(subRes.Result, &changes)
 	if err != nil {
@@ -186,52 +282,151 @@ func (sm *SubstrateManager) ParseResponse(data []byte) ([]subscriber.Event, bool
 		return nil, false
 	}
 
+	// Blocks already read when backfilling are skipped, and
+	// blocks that were skipped since the last one are read
+	var number *uint64
 	var subEvents []subscriber.Event
+	if sm.cursor != nil {
+		n, err := sm.getBlockNumber(changes.Block.Hex())
+		if err != nil {
+			fmt.Println("Failed getting block number:", err)
+		} else if sm.cursor.processed(n, 0) {
+			return nil, true
+		} else {
+			number = &n
+			if cursor := sm.cursor.get(); cursor != nil && n > cursor.BlockNumber+1 {
+				next := cursor.BlockNumber + 1
+				sm.next = &next
+				subEvents = sm.readBlocks(n - 1)
+				if *sm.next != n {
+					// The block is read with the missed blocks later
+					return subEvents, true
+				}
+			}
+		}
+	}
+
+	// A change set is only decoded with metadata known to be valid at its
+	// block. If the block number is known, the block is read again with
+	// the next change set, as the cursor has not been moved past it.
+	if err = sm.refreshMetadata(changes.Block.Hex(), number); err != nil {
+		fmt.Printf("Failed refreshing metadata at block %s, skipping it: %v\n", changes.Block.Hex(), err)
+		return subEvents, true
+	}
+
 	for _, change := range changes.Changes {
 		if !types.Eq(change.StorageKey, sm.key) || !change.HasStorageData {
 			continue
 		}
 
-		events := EventRecords{}
-		err = types.EventRecordsRaw(change.StorageData).DecodeEventRecords(sm.meta, &events)
+		events, err := sm.parseEvents(changes.Block.Hex(), change.StorageData)
 		if err != nil {
 			fmt.Println("Failed parsing EventRecords:", err)
 			continue
 		}
+		subEvents = append(subEvents, events...)
+	}
 
-		for _, request := range events.Chainlink_OracleRequest {
-			// Check if our jobid matches
-			if request.SpecIndex != sm.filter.JobID {
-				continue
-			}
+	if number != nil {
+		sm.cursor.advance(*number, 0)
+	}
 
-			// Check if request is being sent from correct
-			// oracle address
-			found := false
-			for _, address := range sm.filter.Address {
-				if request.OracleAccountID == address.AsAccountID {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
+	return subEvents, true
+}
+
+// parseEvents decodes the System.Events storage data of the block with
+// the hash provided, and returns an event for each event of the pallet
+// matching the filter, or for each OracleRequest matching the job that
+// was not killed, unless the subscription is paused.
+func (sm *SubstrateManager) parseEvents(hash string, data types.StorageDataRaw) ([]subscriber.Event, error) {
+	if sm.pallet != nil {
+		return sm.parsePalletEvents(hash, data)
+	}
+
+	events := EventRecords{}
+	err := types.EventRecordsRaw(data).DecodeEventRecords(sm.meta, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	killed := sm.applyControlEvents(recordControlEvents(events))
+
+	var subEvents []subscriber.Event
+	for _, request := range events.Chainlink_OracleRequest {
+		// Check if our jobid matches
+		if request.SpecIndex != sm.filter.JobID {
+			continue
+		}
 
-			requestParams := convertStringArrayToKV(request.Bytes)
-			requestParams["function"] = string(request.Callback)
-			requestParams["request_id"] = fmt.Sprint(request.RequestIdentifier)
-			requestParams["payment"] = fmt.Sprint(request.Payment)
-			event, err := json.Marshal(requestParams)
-			if err != nil {
-				fmt.Println(err)
-				continue
+		// Check if request is being sent from correct
+		// oracle address
+		found := false
+		for _, address := range sm.filter.Address {
+			if request.OracleAccountID == address.AsAccountID {
+				found = true
+				break
 			}
-			subEvents = append(subEvents, event)
 		}
+		if !found {
+			continue
+		}
+
+		id := fmt.Sprint(request.RequestIdentifier)
+		if killed[id] {
+			fmt.Printf("Dropping killed request %s\n", id)
+			continue
+		}
+		if sm.state.Paused {
+			fmt.Printf("Dropping request %s, the subscription is paused\n", id)
+			continue
+		}
+
+		requestParams := convertStringArrayToKV(request.Bytes)
+		requestParams["function"] = string(request.Callback)
+		requestParams["request_id"] = id
+		requestParams["payment"] = fmt.Sprint(request.Payment)
+		event, err := json.Marshal(requestParams)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		subEvents = append(subEvents, event)
+		sm.requests = appendBounded(sm.requests, id)
 	}
 
-	return subEvents, true
+	return subEvents, nil
+}
+
+// parsePalletEvents decodes the System.Events storage data generically
+// from the metadata, and returns the events matching the pallet filter,
+// unless the subscription is paused. Kills only apply to OracleRequests,
+// as pallet events carry no request ID.
+func (sm *SubstrateManager) parsePalletEvents(hash string, data types.StorageDataRaw) ([]subscriber.Event, error) {
+	events, err := decodeSubstrateEvents(sm.meta, hash, data, sm.pallet.types)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.applyControlEvents(palletControlEvents(events))
+
+	var subEvents []subscriber.Event
+	for _, evt := range events {
+		if !sm.pallet.matches(evt) {
+			continue
+		}
+		if sm.state.Paused {
+			fmt.Printf("Dropping %s.%s event, the subscription is paused\n", evt.Pallet, evt.Event)
+			continue
+		}
+		event, err := json.Marshal(evt)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		subEvents = append(subEvents, event)
+	}
+
+	return subEvents, nil
 }
 
 func (sm *SubstrateManager) GetTestJson() []byte {
@@ -264,7 +459,7 @@ func (sm *SubstrateManager) ParseTestResponse(data []byte) error {
 	}
 
 	sm.meta = &metadata
-	return nil
+	return sm.checkSS58Prefixes()
 }
 
 func convertStringArrayToKV(data []string) map[string]string {
@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"log"
+)
+
+// substrateMaxRequests bounds the number of request IDs remembered
+// per subscription, both emitted and killed. Kills only matter for
+// triggers that have not been delivered yet, so the most recent
+// requests are enough.
+const substrateMaxRequests = 100
+
+// loadSubstrateState loads the state stored for the subscription, e.g.
+// after its operator was unregistered. Returns an empty state if none
+// was stored, or if db is nil.
+func loadSubstrateState(db StateStorer, job string) *store.SubscriptionState {
+	if db != nil {
+		state, err := db.LoadSubscriptionState(job)
+		if err != nil {
+			log.Println("failed loading subscription state:", err)
+		}
+		if state != nil {
+			return state
+		}
+	}
+	return &store.SubscriptionState{Job: job}
+}
+
+// substrateControlEvents are the KillRequest, OperatorRegistered and
+// OperatorUnregistered events of the Chainlink pallet in a block.
+type substrateControlEvents struct {
+	kills        []string
+	registered   []types.AccountID
+	unregistered []types.AccountID
+}
+
+// recordControlEvents returns the control events of records decoded
+// into EventRecords.
+func recordControlEvents(events EventRecords) substrateControlEvents {
+	var c substrateControlEvents
+	for _, kill := range events.Chainlink_KillRequest {
+		c.kills = append(c.kills, fmt.Sprint(kill.RequestIdentifier))
+	}
+	for _, operator := range events.Chainlink_OperatorRegistered {
+		c.registered = append(c.registered, operator.AccountID)
+	}
+	for _, operator := range events.Chainlink_OperatorUnregistered {
+		c.unregistered = append(c.unregistered, operator.AccountID)
+	}
+	return c
+}
+
+// palletControlEvents returns the control events of
+// events decoded from the metadata.
+func palletControlEvents(events []substrateEvent) substrateControlEvents {
+	var c substrateControlEvents
+	for _, evt := range events {
+		if evt.Pallet != "Chainlink" {
+			continue
+		}
+		switch evt.Event {
+		case "KillRequest":
+			c.kills = append(c.kills, fmt.Sprint(evt.Args["arg0"]))
+		case "OperatorRegistered", "OperatorUnregistered":
+			bz, ok := evt.Args["arg0"].(substrateBytes)
+			if !ok || len(bz) != len(types.AccountID{}) {
+				continue
+			}
+			if evt.Event == "OperatorRegistered" {
+				c.registered = append(c.registered, types.NewAccountID(bz))
+			} else {
+				c.unregistered = append(c.unregistered, types.NewAccountID(bz))
+			}
+		}
+	}
+	return c
+}
+
+// applyControlEvents updates the state of the subscription from the
+// control events of the Chainlink pallet, and returns the IDs of the
+// requests killed. Kills of requests already emitted are kept, so that
+// they are dropped if the blocks they were read with are still being read.
+func (sm *SubstrateManager) applyControlEvents(events substrateControlEvents) map[string]bool {
+	changed := false
+
+	killed := make(map[string]bool)
+	for _, id := range events.kills {
+		killed[id] = true
+		if containsString(sm.requests, id) && !containsString(sm.state.KilledRequests, id) {
+			log.Printf("Request %s of job %s was killed\n", id, sm.state.Job)
+			sm.state.KilledRequests = appendBounded(sm.state.KilledRequests, id)
+			changed = true
+		}
+	}
+
+	for _, accountID := range events.unregistered {
+		if sm.isOperator(accountID) && !sm.state.Paused {
+			log.Printf("Operator %#x was unregistered, pausing job %s\n", accountID[:], sm.state.Job)
+			sm.state.Paused = true
+			changed = true
+		}
+	}
+	for _, accountID := range events.registered {
+		if sm.isOperator(accountID) && sm.state.Paused {
+			log.Printf("Operator %#x was registered, resuming job %s\n", accountID[:], sm.state.Job)
+			sm.state.Paused = false
+			changed = true
+		}
+	}
+
+	if changed && sm.db != nil {
+		if err := sm.db.SaveSubscriptionState(sm.state); err != nil {
+			log.Println("failed saving subscription state:", err)
+		}
+	}
+
+	return killed
+}
+
+// dropCancelled drops the events of requests killed, or every event
+// if the subscription is paused, once the blocks the events were read
+// with have been read as well.
+func (sm *SubstrateManager) dropCancelled(events []subscriber.Event) []subscriber.Event {
+	if len(events) == 0 {
+		return events
+	}
+	if sm.state.Paused {
+		fmt.Printf("Dropping %d events, the subscription is paused\n", len(events))
+		return nil
+	}
+
+	var kept []subscriber.Event
+	for _, event := range events {
+		var payload struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(event, &payload); err == nil && payload.RequestID != "" && containsString(sm.state.KilledRequests, payload.RequestID) {
+			fmt.Printf("Dropping killed request %s\n", payload.RequestID)
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+func (sm *SubstrateManager) isOperator(accountID types.AccountID) bool {
+	for _, address := range sm.filter.Address {
+		if address.AsAccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// appendBounded appends the value, dropping the
+// oldest values beyond substrateMaxRequests.
+func appendBounded(values []string, value string) []string {
+	values = append(values, value)
+	if len(values) > substrateMaxRequests {
+		values = values[len(values)-substrateMaxRequests:]
+	}
+	return values
+}
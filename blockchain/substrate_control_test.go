@@ -0,0 +1,243 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+// substrateKillRequest is an encodable Chainlink.KillRequest event record.
+type substrateKillRequest struct {
+	Phase             types.Phase
+	ID                types.EventID
+	RequestIdentifier types.U64
+	Topics            []types.Hash
+}
+
+// substrateControlMetadata returns the test metadata, with the
+// control events of the Chainlink pallet added to it.
+func substrateControlMetadata(t *testing.T) *types.Metadata {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	for i, mod := range metadata.AsMetadataV9.Modules {
+		if string(mod.Name) != "Chainlink" {
+			continue
+		}
+		metadata.AsMetadataV9.Modules[i].Events = append(mod.Events,
+			types.EventMetadataV4{Name: "OperatorRegistered", Args: []types.Type{"AccountId"}},
+			types.EventMetadataV4{Name: "OperatorUnregistered", Args: []types.Type{"AccountId"}},
+			types.EventMetadataV4{Name: "KillRequest", Args: []types.Type{"RequestIdentifier"}},
+		)
+	}
+	return &metadata
+}
+
+// encodeSubstrateRecords returns the System.Events
+// storage data of a block with the records provided.
+func encodeSubstrateRecords(t *testing.T, records ...interface{}) types.StorageDataRaw {
+	data := []byte{byte(len(records) << 2)}
+	for _, record := range records {
+		bz, err := types.EncodeToBytes(record)
+		require.NoError(t, err)
+		data = append(data, bz...)
+	}
+	return data
+}
+
+func TestSubstrateManager_ControlEvents(t *testing.T) {
+	metadata := substrateControlMetadata(t)
+	oracle, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+
+	request := func(id uint64) interface{} {
+		return substrateOracleRequest{
+			Phase:             types.Phase{IsApplyExtrinsic: true},
+			ID:                substrateEventID(t, metadata, "Chainlink", "OracleRequest"),
+			OracleAccountID:   oracle.AsAccountID,
+			SpecIndex:         types.NewText("test-job"),
+			RequestIdentifier: types.NewU64(id),
+			Callback:          types.NewText("callback"),
+			Payment:           types.NewU32(100),
+		}
+	}
+	kill := func(id uint64) interface{} {
+		return substrateKillRequest{
+			Phase:             types.Phase{IsApplyExtrinsic: true},
+			ID:                substrateEventID(t, metadata, "Chainlink", "KillRequest"),
+			RequestIdentifier: types.NewU64(id),
+		}
+	}
+	operator := func(event string) interface{} {
+		return substrateAccountEvent{
+			Phase:     types.Phase{IsApplyExtrinsic: true},
+			ID:        substrateEventID(t, metadata, "Chainlink", event),
+			AccountID: oracle.AsAccountID,
+		}
+	}
+	block := func(records ...interface{}) types.StorageDataRaw {
+		return encodeSubstrateRecords(t, records...)
+	}
+	requestIDs := func(events []subscriber.Event) []string {
+		var ids []string
+		for _, event := range events {
+			var params map[string]string
+			require.NoError(t, json.Unmarshal(event, &params))
+			ids = append(ids, params["request_id"])
+		}
+		return ids
+	}
+
+	db := &mockStateStorer{}
+	sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, db)
+	require.NoError(t, err)
+	sm.meta = metadata
+
+	events, err := sm.parseEvents("0x01", block(request(1)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, requestIDs(events))
+
+	// Kills of emitted requests are stored, and killed
+	// requests of the same block are never emitted
+	events, err = sm.parseEvents("0x02", block(kill(1), request(2), kill(2), kill(3)))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	require.NotNil(t, db.state)
+	assert.Equal(t, store.SQLStringArray{"1"}, db.state.KilledRequests)
+
+	// Requests are dropped while the operator is unregistered
+	events, err = sm.parseEvents("0x03", block(operator("OperatorUnregistered"), request(4)))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.True(t, db.state.Paused)
+
+	// The state is restored after a restart
+	sm, err = createSubstrateManager(subscriber.WS, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, db)
+	require.NoError(t, err)
+	sm.meta = metadata
+
+	events, err = sm.parseEvents("0x04", block(request(5)))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	events, err = sm.parseEvents("0x05", block(operator("OperatorRegistered"), request(6)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"6"}, requestIDs(events))
+	assert.False(t, db.state.Paused)
+	assert.Equal(t, store.SQLStringArray{"1"}, db.state.KilledRequests)
+}
+
+func TestSubstrateManager_dropCancelled(t *testing.T) {
+	metadata := substrateControlMetadata(t)
+	oracle, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+
+	request := substrateOracleRequest{
+		Phase:             types.Phase{IsApplyExtrinsic: true},
+		ID:                substrateEventID(t, metadata, "Chainlink", "OracleRequest"),
+		OracleAccountID:   oracle.AsAccountID,
+		SpecIndex:         types.NewText("test-job"),
+		RequestIdentifier: types.NewU64(1),
+		Callback:          types.NewText("callback"),
+		Payment:           types.NewU32(100),
+	}
+	kill := substrateKillRequest{
+		Phase:             types.Phase{IsApplyExtrinsic: true},
+		ID:                substrateEventID(t, metadata, "Chainlink", "KillRequest"),
+		RequestIdentifier: types.NewU64(1),
+	}
+	unregistered := substrateAccountEvent{
+		Phase:     types.Phase{IsApplyExtrinsic: true},
+		ID:        substrateEventID(t, metadata, "Chainlink", "OperatorUnregistered"),
+		AccountID: oracle.AsAccountID,
+	}
+
+	tests := []struct {
+		name  string
+		later interface{}
+		want  int
+	}{
+		{"keeps requests", substrateAccountEvent{Phase: types.Phase{IsApplyExtrinsic: true}, ID: substrateEventID(t, metadata, "Chainlink", "OperatorRegistered"), AccountID: oracle.AsAccountID}, 1},
+		{"drops requests killed in a later block", kill, 0},
+		{"drops requests of a subscription paused in a later block", unregistered, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+				Job:       "test-job",
+				Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+			}, nil)
+			require.NoError(t, err)
+			sm.meta = metadata
+
+			// The blocks are read together, before the events are handed off
+			events, err := sm.parseEvents("0x01", encodeSubstrateRecords(t, request))
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			later, err := sm.parseEvents("0x02", encodeSubstrateRecords(t, tt.later))
+			require.NoError(t, err)
+
+			assert.Len(t, sm.dropCancelled(append(events, later...)), tt.want)
+		})
+	}
+}
+
+func TestSubstrateManager_ControlEvents_Pallet(t *testing.T) {
+	metadata := substrateControlMetadata(t)
+	oracle, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+
+	// The OracleRequest of the test metadata only has the oracle as arg
+	accountEvent := func(event string) interface{} {
+		return substrateAccountEvent{
+			Phase:     types.Phase{IsApplyExtrinsic: true},
+			ID:        substrateEventID(t, metadata, "Chainlink", event),
+			AccountID: oracle.AsAccountID,
+		}
+	}
+	request := accountEvent("OracleRequest")
+
+	db := &mockStateStorer{}
+	sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+		Job: "test-job",
+		Substrate: store.SubstrateSubscription{
+			AccountIds: []string{substrateTestAddr1},
+			Pallet:     "Chainlink",
+			Event:      "OracleRequest",
+		},
+	}, db)
+	require.NoError(t, err)
+	sm.meta = metadata
+
+	// Events are dropped while the operator is unregistered
+	events, err := sm.parseEvents("0x01", encodeSubstrateRecords(t, accountEvent("OperatorUnregistered"), request))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	require.NotNil(t, db.state)
+	assert.True(t, db.state.Paused)
+
+	events, err = sm.parseEvents("0x02", encodeSubstrateRecords(t, accountEvent("OperatorRegistered"), request))
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.False(t, db.state.Paused)
+}
+
+func TestAppendBounded(t *testing.T) {
+	var values []string
+	for i := 0; i < substrateMaxRequests+2; i++ {
+		values = appendBounded(values, strconv.Itoa(i))
+	}
+	assert.Len(t, values, substrateMaxRequests)
+	assert.Equal(t, "2", values[0])
+}
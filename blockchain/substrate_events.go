@@ -0,0 +1,159 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"regexp"
+)
+
+var substrateArgName = regexp.MustCompile(`^arg[0-9]+$`)
+
+// substratePalletFilter matches the events of a pallet configured by the
+// subscription, decoded from the runtime metadata rather than a Go struct.
+type substratePalletFilter struct {
+	pallet string
+	// event is the name of the event, or empty to match every event of the pallet
+	event string
+	// args maps arg names, e.g. "arg0", to the value they must have
+	args map[string]string
+	// types defines the event arg types the decoder does not know of
+	types map[string]string
+}
+
+func newSubstratePalletFilter(sub store.SubstrateSubscription) (*substratePalletFilter, error) {
+	for name := range sub.Filter {
+		if !substrateArgName.MatchString(name) {
+			return nil, fmt.Errorf("invalid filter arg %s, expected e.g. arg0", name)
+		}
+	}
+	return &substratePalletFilter{
+		pallet: sub.Pallet,
+		event:  sub.Event,
+		args:   sub.Filter,
+		types:  sub.Types,
+	}, nil
+}
+
+// substrateEvent is the payload of a matching pallet event.
+type substrateEvent struct {
+	BlockHash string                 `json:"blockHash"`
+	Pallet    string                 `json:"pallet"`
+	Event     string                 `json:"event"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+func (f *substratePalletFilter) matches(evt substrateEvent) bool {
+	if evt.Pallet != f.pallet || (f.event != "" && evt.Event != f.event) {
+		return false
+	}
+	for name, want := range f.args {
+		value, ok := evt.Args[name]
+		if !ok || !matchesSubstrateValue(value, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// substrateModule is a module of the runtime metadata that emits events.
+type substrateModule struct {
+	name   string
+	events []types.EventMetadataV4
+}
+
+// substrateEventModules returns the modules emitting events, indexed
+// the same way as the module index of the event IDs.
+func substrateEventModules(meta *types.Metadata) ([]substrateModule, error) {
+	var modules []substrateModule
+	switch {
+	case meta.IsMetadataV8:
+		for _, mod := range meta.AsMetadataV8.Modules {
+			if mod.HasEvents {
+				modules = append(modules, substrateModule{string(mod.Name), mod.Events})
+			}
+		}
+	case meta.IsMetadataV9:
+		for _, mod := range meta.AsMetadataV9.Modules {
+			if mod.HasEvents {
+				modules = append(modules, substrateModule{string(mod.Name), mod.Events})
+			}
+		}
+	case meta.IsMetadataV10:
+		for _, mod := range meta.AsMetadataV10.Modules {
+			if mod.HasEvents {
+				modules = append(modules, substrateModule{string(mod.Name), mod.Events})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported metadata version %d", meta.Version)
+	}
+	return modules, nil
+}
+
+// decodeSubstrateEvents decodes the System.Events storage data of
+// a block, naming the args of each event "arg0", "arg1", etc. As
+// records have no length prefix, every arg of every event must be
+// of a type the decoder knows of, or defined in aliases.
+func decodeSubstrateEvents(meta *types.Metadata, hash string, data []byte, aliases map[string]string) ([]substrateEvent, error) {
+	modules, err := substrateEventModules(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &scaleDecoder{data: data, aliases: aliases}
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []substrateEvent
+	for i := 0; i < n; i++ {
+		phase, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch phase {
+		case 0:
+			// ApplyExtrinsic, followed by the extrinsic index
+			if _, err = d.read(4); err != nil {
+				return nil, err
+			}
+		case 1, 2:
+			// Finalization and Initialization
+		default:
+			return nil, fmt.Errorf("invalid phase %d of event record %d", phase, i)
+		}
+
+		id, err := d.read(2)
+		if err != nil {
+			return nil, err
+		}
+		if int(id[0]) >= len(modules) || int(id[1]) >= len(modules[id[0]].events) {
+			return nil, fmt.Errorf("event %v not found in metadata", id)
+		}
+		module := modules[id[0]]
+		em := module.events[id[1]]
+
+		evt := substrateEvent{
+			BlockHash: hash,
+			Pallet:    module.name,
+			Event:     string(em.Name),
+			Args:      make(map[string]interface{}),
+		}
+		for j, arg := range em.Args {
+			value, err := d.decode(string(arg))
+			if err != nil {
+				return nil, fmt.Errorf("failed decoding arg %d of %s.%s: %v", j, evt.Pallet, evt.Event, err)
+			}
+			evt.Args[fmt.Sprintf("arg%d", j)] = value
+		}
+
+		if _, err = d.decode("Vec<Hash>"); err != nil {
+			return nil, err
+		}
+		events = append(events, evt)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// substrateExtrinsicSuccess is an encodable System.ExtrinsicSuccess event record.
+type substrateExtrinsicSuccess struct {
+	Phase        types.Phase
+	ID           types.EventID
+	DispatchInfo types.DispatchInfo
+	Topics       []types.Hash
+}
+
+// substrateAccountEvent is an encodable event record
+// with a single AccountId arg, as in the test metadata.
+type substrateAccountEvent struct {
+	Phase     types.Phase
+	ID        types.EventID
+	AccountID types.AccountID
+	Topics    []types.Hash
+}
+
+func TestSubstrateManager_parsePalletEvents(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	oracle, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+	other, err := types.NewAddressFromHexAccountID(substrateTestAddr2)
+	require.NoError(t, err)
+
+	records := []interface{}{
+		substrateExtrinsicSuccess{
+			Phase:        types.Phase{IsApplyExtrinsic: true},
+			ID:           substrateEventID(t, &metadata, "System", "ExtrinsicSuccess"),
+			DispatchInfo: types.DispatchInfo{Weight: 10000, Class: types.DispatchClass{IsNormal: true}, PaysFee: true},
+		},
+		substrateAccountEvent{
+			Phase:     types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 1},
+			ID:        substrateEventID(t, &metadata, "Chainlink", "OracleRequest"),
+			AccountID: other.AsAccountID,
+		},
+		substrateAccountEvent{
+			Phase:     types.Phase{IsFinalization: true},
+			ID:        substrateEventID(t, &metadata, "Chainlink", "OracleRequest"),
+			AccountID: oracle.AsAccountID,
+			Topics:    []types.Hash{types.NewHash([]byte{0x01})},
+		},
+	}
+	data := []byte{byte(len(records) << 2)}
+	for _, record := range records {
+		bz, err := types.EncodeToBytes(record)
+		require.NoError(t, err)
+		data = append(data, bz...)
+	}
+
+	tests := []struct {
+		name    string
+		sub     store.SubstrateSubscription
+		want    []string
+		wantErr bool
+	}{
+		{
+			"matches events of pallet",
+			store.SubstrateSubscription{Pallet: "Chainlink", Event: "OracleRequest"},
+			[]string{substrateTestAddr2, substrateTestAddr1},
+			false,
+		},
+		{
+			"filters on args",
+			store.SubstrateSubscription{Pallet: "Chainlink", Event: "OracleRequest", Filter: store.SQLStringMap{"arg0": substrateTestAddr1}},
+			[]string{substrateTestAddr1},
+			false,
+		},
+		{
+			"filters on missing args",
+			store.SubstrateSubscription{Pallet: "Chainlink", Event: "OracleRequest", Filter: store.SQLStringMap{"arg1": substrateTestAddr1}},
+			nil,
+			false,
+		},
+		{
+			"filters on event",
+			store.SubstrateSubscription{Pallet: "Chainlink", Event: "OperatorRegistered"},
+			nil,
+			false,
+		},
+		{
+			"fails on unknown types",
+			store.SubstrateSubscription{Pallet: "Chainlink", Types: store.SQLStringMap{"DispatchInfo": "Unknown"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, err := createSubstrateManager(subscriber.WS, store.Subscription{Job: "test-job", Substrate: tt.sub}, nil)
+			require.NoError(t, err)
+			sm.meta = &metadata
+
+			events, err := sm.parseEvents("0x05", data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var accounts []string
+			for _, event := range events {
+				var evt struct {
+					BlockHash string            `json:"blockHash"`
+					Pallet    string            `json:"pallet"`
+					Event     string            `json:"event"`
+					Args      map[string]string `json:"args"`
+				}
+				require.NoError(t, json.Unmarshal(event, &evt))
+				assert.Equal(t, "0x05", evt.BlockHash)
+				assert.Equal(t, "Chainlink", evt.Pallet)
+				assert.Equal(t, "OracleRequest", evt.Event)
+				accounts = append(accounts, evt.Args["arg0"])
+			}
+			assert.Equal(t, tt.want, accounts)
+		})
+	}
+}
+
+func TestCreateSubstrateManager_InvalidFilter(t *testing.T) {
+	_, err := createSubstrateManager(subscriber.WS, store.Subscription{
+		Job: "test-job",
+		Substrate: store.SubstrateSubscription{
+			Pallet: "Chainlink",
+			Filter: store.SQLStringMap{"oracle": substrateTestAddr1},
+		},
+	}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+)
+
+// substrateRuntimeVersion is the part of the result
+// of "state_getRuntimeVersion" used to detect upgrades.
+type substrateRuntimeVersion struct {
+	SpecVersion uint32 `json:"specVersion"`
+}
+
+// substrateSpecRange is a range of blocks known to have the spec version
+// of the current metadata. As the spec version only increases with runtime
+// upgrades, every block between two blocks of the same version has it too.
+type substrateSpecRange struct {
+	from uint64
+	to   uint64
+	// upgraded is the first block known to have a newer version, or 0
+	upgraded uint64
+}
+
+func (r *substrateSpecRange) contains(number uint64) bool {
+	return r != nil && r.from <= number && number <= r.to
+}
+
+func (r *substrateSpecRange) add(number uint64) {
+	if number < r.from {
+		r.from = number
+	}
+	if number > r.to {
+		r.to = number
+	}
+}
+
+// refreshMetadata ensures that the metadata and the System.Events storage
+// key are the ones valid at the block with the hash provided. The metadata
+// is fetched again when the spec version of the runtime at the block is not
+// the version of the current metadata, e.g. after a runtime upgrade. If the
+// number of the block is provided, the spec version is only fetched for
+// blocks outside the range known to have the version of the metadata.
+func (sm *SubstrateManager) refreshMetadata(hash string, number *uint64) error {
+	if sm.meta != nil && number != nil && sm.specRange.contains(*number) {
+		return nil
+	}
+
+	version, err := sm.getSpecVersion(hash)
+	if err != nil {
+		return err
+	}
+	if sm.meta != nil && sm.specVersion != nil && *sm.specVersion == version {
+		if number != nil {
+			sm.addSpecBlock(*number)
+		}
+		return nil
+	}
+
+	var res string
+	if err := callJsonRpc(sm.caller, "state_getMetadata", []interface{}{hash}, &res); err != nil {
+		return err
+	}
+	var metadata types.Metadata
+	if err := types.DecodeFromHexString(res, &metadata); err != nil {
+		return err
+	}
+	key, err := types.CreateStorageKey(&metadata, "System", "Events", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if sm.specVersion != nil {
+		fmt.Printf("Runtime spec version changed from %d to %d at block %s, metadata refreshed\n", *sm.specVersion, version, hash)
+	}
+	sm.meta = &metadata
+	sm.key = key
+	sm.specVersion = &version
+	sm.specRange = nil
+	if number != nil {
+		sm.addSpecBlock(*number)
+	}
+	return nil
+}
+
+func (sm *SubstrateManager) addSpecBlock(number uint64) {
+	if sm.specRange == nil {
+		sm.specRange = &substrateSpecRange{from: number, to: number}
+		return
+	}
+	sm.specRange.add(number)
+}
+
+// extendSpecRange extends the range of blocks known to have the version
+// of the metadata up to the head, or, if the runtime was upgraded since,
+// up to the block before the upgrade, found by bisecting. This way the
+// spec version is not fetched for every block read. It does nothing if
+// the block about to be read is in the range already.
+func (sm *SubstrateManager) extendSpecRange(number, head uint64) {
+	r := sm.specRange
+	if r == nil || sm.specVersion == nil || number <= r.to || head <= r.to {
+		return
+	}
+	if r.upgraded > 0 && number >= r.upgraded {
+		return
+	}
+
+	same, err := sm.hasSpecVersionAt(head, *sm.specVersion)
+	if err != nil {
+		fmt.Printf("Failed getting the runtime version of block %d: %v\n", head, err)
+		return
+	}
+	if same {
+		r.to = head
+		return
+	}
+
+	// The last block of the version is before the head
+	last, upgraded := r.to, head
+	for upgraded-last > 1 {
+		mid := last + (upgraded-last)/2
+		same, err := sm.hasSpecVersionAt(mid, *sm.specVersion)
+		if err != nil {
+			fmt.Printf("Failed getting the runtime version of block %d: %v\n", mid, err)
+			break
+		}
+		if same {
+			last = mid
+		} else {
+			upgraded = mid
+		}
+	}
+	r.to = last
+	r.upgraded = upgraded
+}
+
+// hasSpecVersionAt returns true if the block with the
+// number provided has the spec version provided.
+func (sm *SubstrateManager) hasSpecVersionAt(number uint64, version uint32) (bool, error) {
+	hash, err := sm.getBlockHash(number)
+	if err != nil {
+		return false, err
+	}
+	v, err := sm.getSpecVersion(hash)
+	if err != nil {
+		return false, err
+	}
+	return v == version, nil
+}
+
+func (sm *SubstrateManager) getSpecVersion(hash string) (uint32, error) {
+	var version substrateRuntimeVersion
+	if err := callJsonRpc(sm.caller, "state_getRuntimeVersion", []interface{}{hash}, &version); err != nil {
+		return 0, err
+	}
+	return version.SpecVersion, nil
+}
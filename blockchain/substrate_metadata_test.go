@@ -0,0 +1,223 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSubstrateManager_refreshMetadata(t *testing.T) {
+	versions := map[string]uint32{"0x01": 1, "0x02": 1, "0x03": 2, "0x04": 3}
+	var fetched []string
+	sm := &SubstrateManager{caller: mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []string
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "state_getRuntimeVersion":
+			return substrateRuntimeVersion{SpecVersion: versions[params[0]]}, nil
+		case "state_getMetadata":
+			fetched = append(fetched, params[0])
+			if params[0] == "0x04" {
+				return "0x00", nil
+			}
+			return substrateTestMetadataHex, nil
+		}
+		return nil, errors.New("unexpected method")
+	})}
+
+	require.NoError(t, sm.refreshMetadata("0x01", nil))
+	require.NotNil(t, sm.meta)
+	assert.Equal(t, "0x26aa394eea5630e07c48ae0c9558cef780d41e5e16056765bc8461851072c9d7", sm.key.Hex())
+	assert.Equal(t, uint32(1), *sm.specVersion)
+
+	// The metadata is only fetched again when the version changes
+	require.NoError(t, sm.refreshMetadata("0x02", nil))
+	require.NoError(t, sm.refreshMetadata("0x03", nil))
+	assert.Equal(t, []string{"0x01", "0x03"}, fetched)
+	assert.Equal(t, uint32(2), *sm.specVersion)
+
+	// Invalid metadata is not used, and fetched again for the next block
+	meta := sm.meta
+	require.Error(t, sm.refreshMetadata("0x04", nil))
+	require.Error(t, sm.refreshMetadata("0x04", nil))
+	assert.Equal(t, []string{"0x01", "0x03", "0x04", "0x04"}, fetched)
+	assert.Equal(t, meta, sm.meta)
+	assert.Equal(t, uint32(2), *sm.specVersion)
+}
+
+func TestSubstrateManager_ParseResponse_RuntimeUpgrade(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, nil)
+	require.NoError(t, err)
+	sm.meta = &metadata
+	require.NotNil(t, sm.GetTriggerJson())
+
+	block := types.NewHash([]byte{0x05})
+	var fetched []string
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []string
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+		assert.Equal(t, block.Hex(), params[0])
+
+		switch msg.Method {
+		case "state_getRuntimeVersion":
+			return substrateRuntimeVersion{SpecVersion: 2}, nil
+		case "state_getMetadata":
+			fetched = append(fetched, params[0])
+			return substrateTestMetadataHex, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	changes, err := json.Marshal(map[string]interface{}{
+		"block": block.Hex(),
+		"changes": [][]string{
+			{sm.key.Hex(), encodeOracleRequests(t, &metadata, "test-job")},
+		},
+	})
+	require.NoError(t, err)
+	msg := `{"jsonrpc":"2.0","method":"state_storage","params":{"subscription":1,"result":` + string(changes) + `}}`
+
+	events, ok := sm.ParseResponse([]byte(msg))
+	require.True(t, ok)
+	assert.Len(t, events, 1)
+	assert.Equal(t, []string{block.Hex()}, fetched)
+	assert.Equal(t, uint32(2), *sm.specVersion)
+}
+
+func TestSubstrateManager_ParseResponse_MetadataUnavailable(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	db := &mockStateStorer{}
+	sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, db)
+	require.NoError(t, err)
+	sm.meta = &metadata
+	require.NotNil(t, sm.GetTriggerJson())
+
+	hash := func(n uint64) string {
+		return types.NewHash([]byte{byte(n)}).Hex()
+	}
+	number := func(h string) uint64 {
+		for n := uint64(1); n <= 3; n++ {
+			if hash(n) == h {
+				return n
+			}
+		}
+		t.Fatalf("unknown block %s", h)
+		return 0
+	}
+	storage := func(n uint64) string {
+		return encodeNumberedRequest(t, &metadata, n)
+	}
+
+	unavailable := true
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "chain_getHeader":
+			return types.Header{Number: types.BlockNumber(number(params[0].(string)))}, nil
+		case "chain_getBlockHash":
+			return hash(uint64(params[0].(float64))), nil
+		case "state_getRuntimeVersion":
+			if unavailable && number(params[0].(string)) == 2 {
+				return nil, errors.New("unavailable")
+			}
+			return substrateRuntimeVersion{SpecVersion: 1}, nil
+		case "state_getMetadata":
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			return storage(number(params[1].(string))), nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+	notify := func(n uint64) []subscriber.Event {
+		changes, err := json.Marshal(map[string]interface{}{
+			"block":   hash(n),
+			"changes": [][]string{{sm.key.Hex(), storage(n)}},
+		})
+		require.NoError(t, err)
+		events, ok := sm.ParseResponse([]byte(`{"jsonrpc":"2.0","method":"state_storage","params":{"subscription":1,"result":` + string(changes) + `}}`))
+		require.True(t, ok)
+		return events
+	}
+
+	assert.Equal(t, []string{"1"}, substrateRequestIDs(t, notify(1)))
+
+	// The change set is not decoded without valid metadata
+	assert.Empty(t, notify(2))
+	assert.Equal(t, uint64(1), db.cursor.BlockNumber)
+
+	// The skipped block is read with the next change set
+	unavailable = false
+	assert.Equal(t, []string{"2", "3"}, substrateRequestIDs(t, notify(3)))
+	assert.Equal(t, uint64(3), db.cursor.BlockNumber)
+}
+
+func TestSubstrateManager_readBlocks_RuntimeVersions(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, nil)
+	require.NoError(t, err)
+
+	// The runtime is upgraded at block 40
+	var versionCalls int
+	var fetched []string
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "chain_getBlockHash":
+			return fmt.Sprintf("0x%02x", uint64(params[0].(float64))), nil
+		case "state_getRuntimeVersion":
+			versionCalls++
+			var n uint64
+			_, err := fmt.Sscanf(params[0].(string), "0x%x", &n)
+			require.NoError(t, err)
+			if n < 40 {
+				return substrateRuntimeVersion{SpecVersion: 1}, nil
+			}
+			return substrateRuntimeVersion{SpecVersion: 2}, nil
+		case "state_getMetadata":
+			fetched = append(fetched, params[0].(string))
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			return nil, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	sm.readBlocks(1)
+	require.Equal(t, uint64(2), *sm.next)
+	versionCalls = 0
+
+	// The version is not fetched for every block,
+	// and the upgrade is found by bisecting
+	sm.readBlocks(64)
+	assert.Equal(t, uint64(65), *sm.next)
+	assert.Equal(t, []string{"0x01", "0x28"}, fetched)
+	assert.Equal(t, uint32(2), *sm.specVersion)
+	assert.True(t, versionCalls < 10, "fetched the runtime version %d times", versionCalls)
+}
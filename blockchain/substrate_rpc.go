@@ -0,0 +1,241 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"strings"
+)
+
+// pollBlocks parses the latest header, or in finalized-only mode the
+// hash of the latest finalized head, and reads the events of every block
+// from the next block up to it.
+func (sm *SubstrateManager) pollBlocks(msg jsonrpcMessage) ([]subscriber.Event, bool) {
+	if msg.Error != nil {
+		fmt.Println("Failed getting header:", *msg.Error)
+		return nil, false
+	}
+
+	if sm.finalized {
+		var hash string
+		if err := json.Unmarshal(msg.Result, &hash); err != nil {
+			fmt.Println("Failed parsing finalized head:", err)
+			return nil, false
+		}
+		head, err := sm.getBlockNumber(hash)
+		if err != nil {
+			fmt.Println("Failed getting finalized header:", err)
+			return nil, false
+		}
+		return sm.readBlocks(head), true
+	}
+
+	var header types.Header
+	if err := json.Unmarshal(msg.Result, &header); err != nil {
+		fmt.Println("Failed parsing header:", err)
+		return nil, false
+	}
+
+	return sm.readBlocks(uint64(header.Number)), true
+}
+
+// Backfill implements subscriber.Backfiller. When using WebSocket, it
+// reads the events of every block since the last block processed, up
+// to the latest block, or the latest finalized block in finalized-only
+// mode, so that no events are missed while the connection was down.
+func (sm *SubstrateManager) Backfill() []subscriber.Event {
+	if sm.p != subscriber.WS {
+		return nil
+	}
+
+	cursor := sm.cursor.get()
+	if cursor == nil {
+		return nil
+	}
+
+	head, err := sm.getHead()
+	if err != nil {
+		fmt.Println("Failed getting head:", err)
+		return nil
+	}
+	if cursor.BlockNumber >= head {
+		return nil
+	}
+
+	next := cursor.BlockNumber + 1
+	sm.next = &next
+	events := sm.dropCancelled(sm.readBlocks(head))
+	fmt.Printf("Backfilled %d events from blocks %d to %d\n", len(events), cursor.BlockNumber+1, head)
+	return events
+}
+
+// readBlocks reads the events of every block from the next block up to
+// the head. The first time, it starts after the last block processed,
+// or at the head if none was stored. Reading stops at the first block
+// that cannot be fetched, and resumes from that block on the next call,
+// so that no block is skipped. Blocks whose events cannot be decoded
+// are skipped, as retrying them would never succeed, and so are blocks
+// whose state was pruned by the node, up to the oldest block with state.
+func (sm *SubstrateManager) readBlocks(head uint64) []subscriber.Event {
+	if sm.next == nil {
+		next := head
+		if cursor := sm.cursor.get(); cursor != nil && cursor.BlockNumber < head {
+			next = cursor.BlockNumber + 1
+		}
+		sm.next = &next
+	}
+
+	var subEvents []subscriber.Event
+	for n := *sm.next; n <= head; n++ {
+		sm.extendSpecRange(n, head)
+
+		events, err := sm.getBlockEvents(n)
+		if err != nil && isSubstratePrunedError(err) {
+			oldest, err := sm.findOldestState(n, head)
+			if err != nil {
+				fmt.Printf("Failed finding the oldest block with state after block %d: %v\n", n, err)
+				break
+			}
+			fmt.Printf("WARNING: the node pruned the state of blocks %d to %d, skipping their events. Use an archive node to read them.\n", n, oldest-1)
+			next := oldest
+			sm.next = &next
+			sm.cursor.advance(oldest-1, 0)
+			n = oldest - 1
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Failed reading events of block %d: %v\n", n, err)
+			break
+		}
+		subEvents = append(subEvents, events...)
+
+		next := n + 1
+		sm.next = &next
+		sm.cursor.advance(n, 0)
+	}
+
+	return subEvents
+}
+
+// isSubstratePrunedError returns true if the error is returned by
+// a node for a block whose state it has pruned.
+func isSubstratePrunedError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "state already discarded")
+}
+
+// findOldestState returns the oldest block after the pruned block
+// provided whose state the node still has, up to the head.
+func (sm *SubstrateManager) findOldestState(pruned, head uint64) (uint64, error) {
+	hasState := func(number uint64) (bool, error) {
+		hash, err := sm.getBlockHash(number)
+		if err != nil {
+			return false, err
+		}
+		if _, err := sm.getSpecVersion(hash); err != nil {
+			if isSubstratePrunedError(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	ok, err := hasState(head)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("the state of the head %d was pruned", head)
+	}
+
+	oldest := head
+	for oldest-pruned > 1 {
+		mid := pruned + (oldest-pruned)/2
+		ok, err := hasState(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			oldest = mid
+		} else {
+			pruned = mid
+		}
+	}
+	return oldest, nil
+}
+
+// getHead returns the number of the latest block,
+// or the latest finalized block in finalized-only mode.
+func (sm *SubstrateManager) getHead() (uint64, error) {
+	if !sm.finalized {
+		var header types.Header
+		if err := callJsonRpc(sm.caller, "chain_getHeader", nil, &header); err != nil {
+			return 0, err
+		}
+		return uint64(header.Number), nil
+	}
+
+	var hash string
+	if err := callJsonRpc(sm.caller, "chain_getFinalizedHead", nil, &hash); err != nil {
+		return 0, err
+	}
+	return sm.getBlockNumber(hash)
+}
+
+// getBlockNumber returns the number of the block with the hash provided.
+func (sm *SubstrateManager) getBlockNumber(hash string) (uint64, error) {
+	var header types.Header
+	if err := callJsonRpc(sm.caller, "chain_getHeader", []interface{}{hash}, &header); err != nil {
+		return 0, err
+	}
+	return uint64(header.Number), nil
+}
+
+// getBlockHash returns the hash of the block with the number provided.
+func (sm *SubstrateManager) getBlockHash(number uint64) (string, error) {
+	var hash *string
+	if err := callJsonRpc(sm.caller, "chain_getBlockHash", []interface{}{number}, &hash); err != nil {
+		return "", err
+	}
+	if hash == nil {
+		return "", fmt.Errorf("block %d not found", number)
+	}
+	return *hash, nil
+}
+
+// getBlockEvents reads the System.Events storage at the block with
+// the number provided, and returns the matching events. The events
+// are decoded with the metadata valid at the block. Only failures to
+// fetch the block are returned, decoding errors are logged instead.
+func (sm *SubstrateManager) getBlockEvents(number uint64) ([]subscriber.Event, error) {
+	hash, err := sm.getBlockHash(number)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sm.refreshMetadata(hash, &number); err != nil {
+		return nil, err
+	}
+
+	var storage *string
+	if err := callJsonRpc(sm.caller, "state_getStorageAt", []interface{}{sm.key.Hex(), hash}, &storage); err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		// No events were deposited in the block
+		return nil, nil
+	}
+
+	data, err := types.HexDecodeString(*storage)
+	if err != nil {
+		fmt.Printf("Failed decoding events of block %d, skipping it: %v\n", number, err)
+		return nil, nil
+	}
+	events, err := sm.parseEvents(hash, data)
+	if err != nil {
+		fmt.Printf("Failed decoding events of block %d, skipping it: %v\n", number, err)
+		return nil, nil
+	}
+	return events, nil
+}
@@ -0,0 +1,482 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// substrateEventID returns the ID of an event in the test metadata.
+func substrateEventID(t *testing.T, meta *types.Metadata, module, event string) types.EventID {
+	mi := uint8(0)
+	for _, mod := range meta.AsMetadataV9.Modules {
+		if !mod.HasEvents {
+			continue
+		}
+		if string(mod.Name) == module {
+			for ei, evt := range mod.Events {
+				if string(evt.Name) == event {
+					return types.EventID{mi, uint8(ei)}
+				}
+			}
+		}
+		mi++
+	}
+	t.Fatalf("event %s.%s not found in metadata", module, event)
+	return types.EventID{}
+}
+
+// substrateOracleRequest is an encodable Chainlink.OracleRequest event record.
+type substrateOracleRequest struct {
+	Phase              types.Phase
+	ID                 types.EventID
+	OracleAccountID    types.AccountID
+	SpecIndex          types.Text
+	RequestIdentifier  types.U64
+	RequesterAccountID types.AccountID
+	DataVersion        types.U64
+	Bytes              types.Bytes
+	Callback           types.Text
+	Payment            types.U32
+	Topics             []types.Hash
+}
+
+// encodeOracleRequests returns the System.Events storage
+// data of a block with OracleRequests for the jobs provided.
+func encodeOracleRequests(t *testing.T, meta *types.Metadata, jobs ...string) string {
+	id := substrateEventID(t, meta, "Chainlink", "OracleRequest")
+	oracle, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+
+	var records []substrateOracleRequest
+	for i, job := range jobs {
+		records = append(records, substrateOracleRequest{
+			Phase:             types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 1},
+			ID:                id,
+			OracleAccountID:   oracle.AsAccountID,
+			SpecIndex:         types.NewText(job),
+			RequestIdentifier: types.NewU64(uint64(i + 1)),
+			Callback:          types.NewText("callback"),
+			Payment:           types.NewU32(100),
+		})
+	}
+
+	data, err := types.EncodeToHexString(records)
+	require.NoError(t, err)
+	return data
+}
+
+// encodeNumberedRequest returns the System.Events storage data of the
+// block with the number provided, with a request with ID n for the job.
+func encodeNumberedRequest(t *testing.T, meta *types.Metadata, n uint64) string {
+	jobs := make([]string, n)
+	for i := range jobs {
+		jobs[i] = "other-job"
+	}
+	jobs[n-1] = "test-job"
+	return encodeOracleRequests(t, meta, jobs...)
+}
+
+// substrateRequestIDs returns the request IDs of the events.
+func substrateRequestIDs(t *testing.T, events []subscriber.Event) []string {
+	var ids []string
+	for _, event := range events {
+		var params map[string]string
+		require.NoError(t, json.Unmarshal(event, &params))
+		ids = append(ids, params["request_id"])
+	}
+	return ids
+}
+
+func TestSubstrateManager_RPC(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, nil)
+	require.NoError(t, err)
+	sm.meta = &metadata
+
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"chain_getHeader"}`, string(sm.GetTriggerJson()))
+
+	storage := map[string]interface{}{
+		"0x03": encodeOracleRequests(t, &metadata, "test-job", "other-job"),
+		"0x05": encodeOracleRequests(t, &metadata, "test-job"),
+	}
+	var read []uint64
+	failAt := uint64(0)
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "chain_getBlockHash":
+			number := uint64(params[0].(float64))
+			if number == failAt {
+				return nil, errors.New("unavailable")
+			}
+			return fmt.Sprintf("0x%02x", number), nil
+		case "state_getRuntimeVersion":
+			return substrateRuntimeVersion{SpecVersion: 1}, nil
+		case "state_getMetadata":
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			assert.Equal(t, sm.key.Hex(), params[0])
+			var number uint64
+			_, err := fmt.Sscanf(params[1].(string), "0x%x", &number)
+			require.NoError(t, err)
+			read = append(read, number)
+			return storage[params[1].(string)], nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	header := func(number uint32) []byte {
+		bz, err := json.Marshal(types.Header{Number: types.BlockNumber(number)})
+		require.NoError(t, err)
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":` + string(bz) + `}`)
+	}
+
+	// The first poll starts at the latest block
+	events, ok := sm.ParseResponse(header(2))
+	require.True(t, ok)
+	assert.Empty(t, events)
+	assert.Equal(t, []uint64{2}, read)
+
+	// A block that cannot be read is retried on the next poll
+	failAt = 4
+	events, ok = sm.ParseResponse(header(5))
+	require.True(t, ok)
+	require.Len(t, events, 1)
+	assert.Equal(t, []uint64{2, 3}, read)
+
+	var params map[string]string
+	require.NoError(t, json.Unmarshal(events[0], &params))
+	assert.Equal(t, map[string]string{
+		"function":   "callback",
+		"request_id": "1",
+		"payment":    "100",
+	}, params)
+
+	failAt = 0
+	events, ok = sm.ParseResponse(header(5))
+	require.True(t, ok)
+	require.Len(t, events, 1)
+	assert.Equal(t, []uint64{2, 3, 4, 5}, read)
+
+	events, ok = sm.ParseResponse(header(5))
+	require.True(t, ok)
+	assert.Empty(t, events)
+	assert.Equal(t, []uint64{2, 3, 4, 5}, read)
+}
+
+func TestSubstrateManager_RPC_UndecodableBlock(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, nil)
+	require.NoError(t, err)
+	sm.meta = &metadata
+	require.NotNil(t, sm.GetTriggerJson())
+
+	storage := map[string]interface{}{
+		"0x03": "0x04ffff",
+		"0x04": encodeOracleRequests(t, &metadata, "test-job"),
+	}
+	var read []uint64
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "chain_getBlockHash":
+			return fmt.Sprintf("0x%02x", uint64(params[0].(float64))), nil
+		case "state_getRuntimeVersion":
+			return substrateRuntimeVersion{SpecVersion: 1}, nil
+		case "state_getMetadata":
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			var number uint64
+			_, err := fmt.Sscanf(params[1].(string), "0x%x", &number)
+			require.NoError(t, err)
+			read = append(read, number)
+			return storage[params[1].(string)], nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	header := func(number uint32) []byte {
+		bz, err := json.Marshal(types.Header{Number: types.BlockNumber(number)})
+		require.NoError(t, err)
+		return []byte(`{"jsonrpc":"2.0","id":1,"result":` + string(bz) + `}`)
+	}
+
+	_, ok := sm.ParseResponse(header(2))
+	require.True(t, ok)
+
+	// The undecodable block is skipped, instead of being retried forever
+	events, ok := sm.ParseResponse(header(4))
+	require.True(t, ok)
+	assert.Len(t, events, 1)
+	assert.Equal(t, []uint64{2, 3, 4}, read)
+
+	events, ok = sm.ParseResponse(header(4))
+	require.True(t, ok)
+	assert.Empty(t, events)
+	assert.Equal(t, []uint64{2, 3, 4}, read)
+}
+
+func TestSubstrateManager_Finalized(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	storage := map[string]interface{}{
+		"0x03": encodeOracleRequests(t, &metadata, "test-job"),
+		"0x05": encodeOracleRequests(t, &metadata, "test-job", "test-job"),
+	}
+	finalizedHead := func(read *[]uint64) subscriber.Caller {
+		return mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+			var params []interface{}
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+			switch msg.Method {
+			case "chain_getHeader":
+				return types.Header{Number: types.BlockNumber(5)}, nil
+			case "chain_getBlockHash":
+				return fmt.Sprintf("0x%02x", uint64(params[0].(float64))), nil
+			case "state_getRuntimeVersion":
+				return substrateRuntimeVersion{SpecVersion: 1}, nil
+			case "state_getMetadata":
+				return substrateTestMetadataHex, nil
+			case "state_getStorageAt":
+				var number uint64
+				_, err := fmt.Sscanf(params[1].(string), "0x%x", &number)
+				require.NoError(t, err)
+				*read = append(*read, number)
+				return storage[params[1].(string)], nil
+			}
+			return nil, errors.New("unexpected method")
+		})
+	}
+
+	t.Run("follows finalized heads over WS", func(t *testing.T) {
+		sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+			Job:       "test-job",
+			Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}, Finalized: true},
+		}, nil)
+		require.NoError(t, err)
+		sm.meta = &metadata
+		var read []uint64
+		sm.caller = finalizedHead(&read)
+
+		assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"chain_subscribeFinalizedHeads"}`, string(sm.GetTriggerJson()))
+
+		header := func(number uint32) []byte {
+			bz, err := json.Marshal(types.Header{Number: types.BlockNumber(number)})
+			require.NoError(t, err)
+			return []byte(`{"jsonrpc":"2.0","method":"chain_finalizedHead","params":{"subscription":1,"result":` + string(bz) + `}}`)
+		}
+
+		events, ok := sm.ParseResponse(header(3))
+		require.True(t, ok)
+		assert.Len(t, events, 1)
+		assert.Equal(t, []uint64{3}, read)
+
+		// Finalized blocks skipped between notifications are read
+		events, ok = sm.ParseResponse(header(5))
+		require.True(t, ok)
+		assert.Len(t, events, 2)
+		assert.Equal(t, []uint64{3, 4, 5}, read)
+	})
+
+	t.Run("polls the finalized head over RPC", func(t *testing.T) {
+		sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+			Job:       "test-job",
+			Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}, Finalized: true},
+		}, nil)
+		require.NoError(t, err)
+		sm.meta = &metadata
+		var read []uint64
+		sm.caller = finalizedHead(&read)
+
+		assert.Equal(t, `{"jsonrpc":"2.0","id":1,"method":"chain_getFinalizedHead"}`, string(sm.GetTriggerJson()))
+
+		events, ok := sm.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x05"}`))
+		require.True(t, ok)
+		assert.Len(t, events, 2)
+		assert.Equal(t, []uint64{5}, read)
+	})
+}
+
+func TestSubstrateManager_Backfill(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	storage := func(n uint64) string {
+		return encodeNumberedRequest(t, &metadata, n)
+	}
+	hash := func(n uint64) string {
+		return types.NewHash([]byte{byte(n)}).Hex()
+	}
+
+	db := &mockStateStorer{}
+	newManager := func() *SubstrateManager {
+		sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+			Job:       "test-job",
+			Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+		}, db)
+		require.NoError(t, err)
+		sm.meta = &metadata
+		require.NotNil(t, sm.GetTriggerJson())
+		return sm
+	}
+
+	head := uint64(1)
+	var read []uint64
+	caller := mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		if len(msg.Params) > 0 {
+			require.NoError(t, json.Unmarshal(msg.Params, &params))
+		}
+
+		switch msg.Method {
+		case "chain_getHeader":
+			if len(params) == 0 {
+				return types.Header{Number: types.BlockNumber(head)}, nil
+			}
+			for n := uint64(1); n <= head; n++ {
+				if hash(n) == params[0] {
+					return types.Header{Number: types.BlockNumber(n)}, nil
+				}
+			}
+			return nil, errors.New("unknown block")
+		case "chain_getBlockHash":
+			return hash(uint64(params[0].(float64))), nil
+		case "state_getRuntimeVersion":
+			return substrateRuntimeVersion{SpecVersion: 1}, nil
+		case "state_getMetadata":
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			for n := uint64(1); n <= head; n++ {
+				if hash(n) == params[1] {
+					read = append(read, n)
+					return storage(n), nil
+				}
+			}
+			return nil, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+	notify := func(sm *SubstrateManager, n uint64) []subscriber.Event {
+		changes, err := json.Marshal(map[string]interface{}{
+			"block":   hash(n),
+			"changes": [][]string{{sm.key.Hex(), storage(n)}},
+		})
+		require.NoError(t, err)
+		msg := `{"jsonrpc":"2.0","method":"state_storage","params":{"subscription":1,"result":` + string(changes) + `}}`
+		events, ok := sm.ParseResponse([]byte(msg))
+		require.True(t, ok)
+		return events
+	}
+
+	sm := newManager()
+	sm.caller = caller
+
+	// Nothing is backfilled before a block was processed
+	assert.Empty(t, sm.Backfill())
+	assert.Equal(t, []string{"1"}, substrateRequestIDs(t, notify(sm, 1)))
+	require.NotNil(t, db.cursor)
+	assert.Equal(t, uint64(1), db.cursor.BlockNumber)
+
+	// The blocks produced while disconnected are read in order
+	head = 4
+	assert.Equal(t, []string{"2", "3", "4"}, substrateRequestIDs(t, sm.Backfill()))
+	assert.Equal(t, []uint64{2, 3, 4}, read)
+	assert.Equal(t, uint64(4), db.cursor.BlockNumber)
+
+	// Blocks already backfilled are not delivered twice
+	assert.Empty(t, notify(sm, 4))
+	head = 5
+	assert.Equal(t, []string{"5"}, substrateRequestIDs(t, notify(sm, 5)))
+
+	// The last block processed is kept across restarts
+	head = 6
+	sm = newManager()
+	sm.caller = caller
+	assert.Equal(t, []string{"6"}, substrateRequestIDs(t, sm.Backfill()))
+	assert.Equal(t, []uint64{2, 3, 4, 6}, read)
+	assert.Empty(t, sm.Backfill())
+}
+
+func TestSubstrateManager_RPC_PrunedState(t *testing.T) {
+	var metadata types.Metadata
+	require.NoError(t, types.DecodeFromHexString(substrateTestMetadataHex, &metadata))
+
+	db := &mockStateStorer{cursor: &store.BlockCursor{BlockNumber: 2}}
+	sm, err := createSubstrateManager(subscriber.RPC, store.Subscription{
+		Job:       "test-job",
+		Substrate: store.SubstrateSubscription{AccountIds: []string{substrateTestAddr1}},
+	}, db)
+	require.NoError(t, err)
+	sm.meta = &metadata
+
+	// The node only has the state of the blocks from 10
+	number := func(hash string) uint64 {
+		var n uint64
+		_, err := fmt.Sscanf(hash, "0x%x", &n)
+		require.NoError(t, err)
+		return n
+	}
+	pruned := func(hash string) error {
+		if number(hash) < 10 {
+			return fmt.Errorf("Client error: UnknownBlock: State already discarded for BlockId::Hash(%s)", hash)
+		}
+		return nil
+	}
+	sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+		var params []interface{}
+		require.NoError(t, json.Unmarshal(msg.Params, &params))
+
+		switch msg.Method {
+		case "chain_getBlockHash":
+			return fmt.Sprintf("0x%02x", uint64(params[0].(float64))), nil
+		case "state_getRuntimeVersion":
+			if err := pruned(params[0].(string)); err != nil {
+				return nil, err
+			}
+			return substrateRuntimeVersion{SpecVersion: 1}, nil
+		case "state_getMetadata":
+			return substrateTestMetadataHex, nil
+		case "state_getStorageAt":
+			hash := params[1].(string)
+			if err := pruned(hash); err != nil {
+				return nil, err
+			}
+			if n := number(hash); n == 3 || n == 11 {
+				return encodeNumberedRequest(t, &metadata, n), nil
+			}
+			return nil, nil
+		}
+		return nil, errors.New("unexpected method")
+	})
+
+	bz, err := json.Marshal(types.Header{Number: types.BlockNumber(12)})
+	require.NoError(t, err)
+
+	// The pruned blocks are skipped instead of being retried forever
+	events, ok := sm.ParseResponse([]byte(`{"jsonrpc":"2.0","id":1,"result":` + string(bz) + `}`))
+	require.True(t, ok)
+	assert.Equal(t, []string{"11"}, substrateRequestIDs(t, events))
+	assert.Equal(t, uint64(12), db.cursor.BlockNumber)
+}
@@ -0,0 +1,324 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxTypeDepth limits the nesting of types and aliases,
+// so that a recursive alias cannot loop forever.
+const maxTypeDepth = 32
+
+// substrateTypeAliases are the definitions of the Substrate types commonly
+// found in event args, with the same layout as go-substrate-rpc-client.
+// Subscriptions can add their own aliases, which take precedence.
+var substrateTypeAliases = map[string]string{
+	"AccountId":         "[u8; 32]",
+	"AuthorityId":       "[u8; 32]",
+	"Hash":              "[u8; 32]",
+	"H256":              "[u8; 32]",
+	"Balance":           "u128",
+	"BalanceOf":         "u128",
+	"BlockNumber":       "u32",
+	"AccountIndex":      "u32",
+	"EventIndex":        "u32",
+	"SessionIndex":      "u32",
+	"Index":             "u32",
+	"Moment":            "u64",
+	"AuthorityWeight":   "u64",
+	"Weight":            "u32",
+	"AuthorityList":     "Vec<(AuthorityId, AuthorityWeight)>",
+	"DispatchInfo":      "(Weight, DispatchClass, bool)",
+	"DispatchClass":     "u8",
+	"DispatchError":     "(Option<u8>, u8)",
+	"Bytes":             "Vec<u8>",
+	"Text":              "Vec<u8>",
+	"String":            "Vec<u8>",
+	"SpecIndex":         "Vec<u8>",
+	"RequestIdentifier": "u64",
+	"DataVersion":       "u64",
+}
+
+// substrateBytes is a decoded byte array, encoded to JSON as hex.
+type substrateBytes []byte
+
+func (b substrateBytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + hexutil.Encode(b) + `"`), nil
+}
+
+// scaleDecoder decodes SCALE encoded values, using the
+// definitions of type names found in the runtime metadata.
+type scaleDecoder struct {
+	data    []byte
+	aliases map[string]string
+}
+
+func (d *scaleDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data) {
+		return nil, errors.New("unexpected end of data")
+	}
+	bz := d.data[:n]
+	d.data = d.data[n:]
+	return bz, nil
+}
+
+func (d *scaleDecoder) readByte() (byte, error) {
+	bz, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return bz[0], nil
+}
+
+// compact decodes a compact encoded unsigned integer.
+func (d *scaleDecoder) compact() (*big.Int, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var bz []byte
+	switch b & 3 {
+	case 0:
+		return big.NewInt(int64(b >> 2)), nil
+	case 1:
+		bz, err = d.read(1)
+	case 2:
+		bz, err = d.read(3)
+	case 3:
+		bz, err = d.read(int(b>>2) + 4)
+		if err != nil {
+			return nil, err
+		}
+		return littleEndianInt(bz), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := littleEndianInt(append([]byte{b}, bz...))
+	return n.Rsh(n, 2), nil
+}
+
+// length decodes the compact encoded length of a collection.
+func (d *scaleDecoder) length() (int, error) {
+	n, err := d.compact()
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsInt64() || n.Int64() > int64(len(d.data)) {
+		return 0, fmt.Errorf("invalid length %s", n)
+	}
+	return int(n.Int64()), nil
+}
+
+func littleEndianInt(bz []byte) *big.Int {
+	be := make([]byte, len(bz))
+	for i, b := range bz {
+		be[len(bz)-1-i] = b
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// decode decodes a value of the type provided. Integers are decoded as
+// decimal strings, byte arrays as substrateBytes, and collections and
+// tuples as slices.
+func (d *scaleDecoder) decode(typ string) (interface{}, error) {
+	return d.decodeType(typ, 0)
+}
+
+func (d *scaleDecoder) decodeType(typ string, depth int) (interface{}, error) {
+	if depth > maxTypeDepth {
+		return nil, fmt.Errorf("type %s is nested too deeply", typ)
+	}
+	typ = normalizeTypeName(typ)
+
+	if strings.HasPrefix(typ, "(") && strings.HasSuffix(typ, ")") {
+		values := []interface{}{}
+		for _, elem := range splitTypeList(typ[1 : len(typ)-1]) {
+			value, err := d.decodeType(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	if strings.HasPrefix(typ, "[") && strings.HasSuffix(typ, "]") {
+		parts := strings.Split(typ[1:len(typ)-1], ";")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid array type %s", typ)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid array type %s", typ)
+		}
+		return d.decodeSequence(strings.TrimSpace(parts[0]), n, depth)
+	}
+
+	name, params := splitGenericType(typ)
+	switch name {
+	case "Vec":
+		if len(params) != 1 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeSequence(params[0], n, depth)
+	case "Option":
+		if len(params) != 1 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if normalizeTypeName(params[0]) == "bool" {
+			// Option<bool> is encoded as a single byte
+			if b == 0 {
+				return nil, nil
+			}
+			return b == 1, nil
+		}
+		if b == 0 {
+			return nil, nil
+		}
+		return d.decodeType(params[0], depth+1)
+	case "Compact":
+		n, err := d.compact()
+		if err != nil {
+			return nil, err
+		}
+		return n.String(), nil
+	case "Box":
+		if len(params) != 1 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		return d.decodeType(params[0], depth+1)
+	case "bool":
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return b != 0, nil
+	}
+
+	if size, signed, ok := integerType(name); ok {
+		bz, err := d.read(size / 8)
+		if err != nil {
+			return nil, err
+		}
+		n := littleEndianInt(bz)
+		if signed && n.Bit(size-1) == 1 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(size)))
+		}
+		return n.String(), nil
+	}
+
+	if alias, ok := d.aliases[name]; ok {
+		return d.decodeType(alias, depth+1)
+	}
+	if alias, ok := substrateTypeAliases[name]; ok {
+		return d.decodeType(alias, depth+1)
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+// decodeSequence decodes n values of the type provided. Byte
+// sequences are returned as substrateBytes.
+func (d *scaleDecoder) decodeSequence(typ string, n int, depth int) (interface{}, error) {
+	if normalizeTypeName(typ) == "u8" {
+		bz, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return substrateBytes(append([]byte{}, bz...)), nil
+	}
+
+	values := []interface{}{}
+	for i := 0; i < n; i++ {
+		value, err := d.decodeType(typ, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func integerType(name string) (size int, signed bool, ok bool) {
+	if len(name) < 2 || (name[0] != 'u' && name[0] != 'i') {
+		return 0, false, false
+	}
+	size, err := strconv.Atoi(name[1:])
+	if err != nil || size < 8 || size > 256 || size%8 != 0 {
+		return 0, false, false
+	}
+	return size, name[0] == 'i', true
+}
+
+// normalizeTypeName removes whitespace and the path of a type name
+// as found in the metadata, e.g. "<T as Trait>::Balance" and
+// "T::Balance" are both normalized to "Balance".
+func normalizeTypeName(typ string) string {
+	typ = strings.Join(strings.Fields(typ), "")
+	if i := strings.LastIndex(typ, "::"); i >= 0 && !strings.ContainsAny(typ[i:], "<>(),;[]") {
+		typ = typ[i+2:]
+	}
+	return typ
+}
+
+// splitGenericType splits a type such as "Vec<u8>" into its name and
+// its type params. Type params of other types than the generic types
+// known to the decoder are dropped, e.g. "BalanceOf<T>" is "BalanceOf".
+func splitGenericType(typ string) (string, []string) {
+	i := strings.Index(typ, "<")
+	if i < 0 || !strings.HasSuffix(typ, ">") {
+		return typ, nil
+	}
+	return typ[:i], splitTypeList(typ[i+1 : len(typ)-1])
+}
+
+// splitTypeList splits a comma separated list of types,
+// ignoring the commas nested in other types.
+func splitTypeList(list string) []string {
+	var types []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '<', '(', '[':
+			depth++
+		case '>', ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				types = append(types, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(list[start:]); last != "" {
+		types = append(types, last)
+	}
+	return types
+}
+
+// matchesSubstrateValue returns true if the decoded value equals the
+// filter value. Byte arrays match their hex encoding, or their text.
+func matchesSubstrateValue(value interface{}, want string) bool {
+	if bz, ok := value.(substrateBytes); ok {
+		if strings.EqualFold(strings.TrimPrefix(want, "0x"), hex.EncodeToString(bz)) {
+			return true
+		}
+		return string(bz) == want
+	}
+	return fmt.Sprint(value) == want
+}
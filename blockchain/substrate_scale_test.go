@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestScaleDecoder_decode(t *testing.T) {
+	account := "0x" + "7c522c8273973e7bcf4a5dbfcc745dba4a3ab08c1e410167d7b1bdf9cb924f6c"
+	accountBytes, err := types.HexDecodeString(account)
+	require.NoError(t, err)
+
+	aliases := map[string]string{
+		"RequestId": "u64",
+		"Loop":      "Loop",
+	}
+
+	tests := []struct {
+		name    string
+		typ     string
+		data    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"u32", "u32", "0x01000000", "1", false},
+		{"negative i16", "i16", "0xfeff", "-2", false},
+		{"u128", "u128", "0x00e87648170000000000000000000000", "100000000000", false},
+		{"bool", "bool", "0x01", true, false},
+		{"single byte compact", "Compact<u32>", "0x04", "1", false},
+		{"two byte compact", "Compact<Balance>", "0x0501", "65", false},
+		{"bytes", "Vec<u8>", "0x0c616263", substrateBytes("abc"), false},
+		{"account id with path", "T::AccountId", account, substrateBytes(accountBytes), false},
+		{"balance with trait path", "<T as Trait>::Balance", "0x01000000000000000000000000000000", "1", false},
+		{"generic alias", "BalanceOf<T>", "0x02000000000000000000000000000000", "2", false},
+		{"none", "Option<u32>", "0x00", nil, false},
+		{"some", "Option<u32>", "0x0102000000", "2", false},
+		{"option bool", "Option<bool>", "0x02", false, false},
+		{"tuple", "(u8, Vec<u16>)", "0x070801000200", []interface{}{"7", []interface{}{"1", "2"}}, false},
+		{"fixed array", "[u16; 2]", "0x01000200", []interface{}{"1", "2"}, false},
+		{"dispatch error", "DispatchError", "0x010203", []interface{}{"2", "3"}, false},
+		{"custom alias", "RequestId", "0x0300000000000000", "3", false},
+		{"unsupported type", "Foo", "0x00", nil, true},
+		{"end of data", "u32", "0x0100", nil, true},
+		{"invalid length", "Vec<u8>", "0x0c61", nil, true},
+		{"recursive alias", "Loop", "0x00", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := types.HexDecodeString(tt.data)
+			require.NoError(t, err)
+
+			d := &scaleDecoder{data: data, aliases: aliases}
+			got, err := d.decode(tt.typ)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Empty(t, d.data)
+		})
+	}
+}
+
+func TestMatchesSubstrateValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		match bool
+	}{
+		{"integer", "100", "100", true},
+		{"other integer", "100", "101", false},
+		{"bool", true, "true", true},
+		{"bytes as hex", substrateBytes{0xab, 0xcd}, "0xABCD", true},
+		{"bytes as hex without prefix", substrateBytes{0xab, 0xcd}, "abcd", true},
+		{"bytes as text", substrateBytes("test-job"), "test-job", true},
+		{"other bytes", substrateBytes("test-job"), "other-job", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.match, matchesSubstrateValue(tt.value, tt.want))
+		})
+	}
+}
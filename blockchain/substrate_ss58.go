@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"golang.org/x/crypto/blake2b"
+	"math/big"
+	"strings"
+)
+
+// substrateDefaultSS58Prefix is the network prefix of generic
+// Substrate chains, used when the chain does not report one.
+const substrateDefaultSS58Prefix = 42
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var ss58ChecksumPrefix = []byte("SS58PRE")
+
+// substrateProperties is the part of the result of
+// "system_properties" used to validate SS58 addresses.
+type substrateProperties struct {
+	SS58Format *uint16 `json:"ss58Format"`
+}
+
+// parseSubstrateAccountID parses an account ID given either as hex, with
+// or without the 0x prefix, or as an SS58 address. The network prefix of
+// an SS58 address is returned with the account ID, so that it can be
+// checked against the chain.
+func parseSubstrateAccountID(id string) (types.AccountID, *uint16, error) {
+	if strings.HasPrefix(id, "0x") || isHexAccountID(id) {
+		bz, err := types.HexDecodeString(id)
+		if err != nil {
+			return types.AccountID{}, nil, err
+		}
+		if len(bz) != len(types.AccountID{}) {
+			return types.AccountID{}, nil, fmt.Errorf("invalid account ID length %d", len(bz))
+		}
+		return types.NewAccountID(bz), nil, nil
+	}
+
+	prefix, accountID, err := decodeSS58(id)
+	if err != nil {
+		return types.AccountID{}, nil, err
+	}
+	return accountID, &prefix, nil
+}
+
+// isHexAccountID returns true if the id is an
+// account ID in hex without the 0x prefix.
+func isHexAccountID(id string) bool {
+	if len(id) != 2*len(types.AccountID{}) {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// decodeSS58 decodes an SS58 address into its network prefix and
+// account ID, verifying the checksum.
+func decodeSS58(address string) (uint16, types.AccountID, error) {
+	data, err := decodeBase58(address)
+	if err != nil {
+		return 0, types.AccountID{}, err
+	}
+	if len(data) == 0 {
+		return 0, types.AccountID{}, errors.New("empty SS58 address")
+	}
+
+	var prefix uint16
+	var prefixLen int
+	switch {
+	case data[0] < 64:
+		prefix, prefixLen = uint16(data[0]), 1
+	case data[0] < 128 && len(data) > 1:
+		lower := (data[0]&0x3f)<<2 | data[1]>>6
+		upper := data[1] & 0x3f
+		prefix, prefixLen = uint16(lower)|uint16(upper)<<8, 2
+	default:
+		return 0, types.AccountID{}, errors.New("invalid SS58 network prefix")
+	}
+
+	if len(data) != prefixLen+32+2 {
+		return 0, types.AccountID{}, fmt.Errorf("invalid SS58 address length %d", len(data))
+	}
+
+	body := data[:prefixLen+32]
+	hash := blake2b.Sum512(append(append([]byte{}, ss58ChecksumPrefix...), body...))
+	if !bytes.Equal(hash[:2], data[prefixLen+32:]) {
+		return 0, types.AccountID{}, errors.New("invalid SS58 checksum")
+	}
+
+	return prefix, types.NewAccountID(body[prefixLen:]), nil
+}
+
+// decodeBase58 decodes a string with the Bitcoin base58 alphabet.
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	// Leading zeros are encoded as leading ones
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// checkSS58Prefixes verifies that the network prefix of
+// every SS58 address of the filter is the one of the chain,
+// and returns a ValidationError naming the address if not.
+func (sm *SubstrateManager) checkSS58Prefixes() error {
+	if len(sm.prefixes) == 0 {
+		return nil
+	}
+
+	var props substrateProperties
+	if err := callJsonRpc(sm.caller, "system_properties", nil, &props); err != nil {
+		return err
+	}
+	network := uint16(substrateDefaultSS58Prefix)
+	if props.SS58Format != nil {
+		network = *props.SS58Format
+	}
+
+	for address, prefix := range sm.prefixes {
+		if prefix != network {
+			return ValidationError{fmt.Errorf("account ID %s has SS58 network prefix %d, but the chain uses %d", address, prefix, network)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"errors"
+	"github.com/centrifuge/go-substrate-rpc-client/types"
+	"github.com/smartcontractkit/external-initiator/store"
+	"github.com/smartcontractkit/external-initiator/subscriber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseSubstrateAccountID(t *testing.T) {
+	alice, err := types.NewAddressFromHexAccountID(substrateTestAddr1)
+	require.NoError(t, err)
+	bob, err := types.NewAddressFromHexAccountID(substrateTestAddr2)
+	require.NoError(t, err)
+
+	prefix := func(p uint16) *uint16 { return &p }
+
+	tests := []struct {
+		name       string
+		id         string
+		wantID     types.AccountID
+		wantPrefix *uint16
+		wantErr    bool
+	}{
+		{"hex account ID", substrateTestAddr1, alice.AsAccountID, nil, false},
+		{"hex account ID without prefix", strings.TrimPrefix(substrateTestAddr1, "0x"), alice.AsAccountID, nil, false},
+		{"generic Substrate address", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", alice.AsAccountID, prefix(42), false},
+		{"Polkadot address", "15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5", alice.AsAccountID, prefix(0), false},
+		{"Kusama address", "HNZata7iMYWmk5RvZRTiAsSDhV8366zq2YGb3tLH5Upf74F", alice.AsAccountID, prefix(2), false},
+		{"two byte prefix", "tKHsVs1DAWFP4yVgxqFywS96ca9cZYm3uS7e1TpWsChX4tj3H", bob.AsAccountID, prefix(1234), false},
+		{"invalid checksum", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ", types.AccountID{}, nil, true},
+		{"invalid length", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHG", types.AccountID{}, nil, true},
+		{"invalid character", "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKut0Y", types.AccountID{}, nil, true},
+		{"invalid hex", "0x1234", types.AccountID{}, nil, true},
+		{"empty", "", types.AccountID{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, prefix, err := parseSubstrateAccountID(tt.id)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantPrefix, prefix)
+		})
+	}
+}
+
+func TestSubstrateManager_checkSS58Prefixes(t *testing.T) {
+	tests := []struct {
+		name       string
+		accountIds []string
+		properties interface{}
+		wantErr    bool
+	}{
+		{
+			"matches chain prefix",
+			[]string{"15oF4uVJwmo4TdGW7VfQxNLavjCXviqxT9S1MgbjMNHr6Sp5"},
+			map[string]interface{}{"ss58Format": 0, "tokenSymbol": "DOT"},
+			false,
+		},
+		{
+			"defaults to generic Substrate prefix",
+			[]string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"},
+			map[string]interface{}{},
+			false,
+		},
+		{
+			"fails on other network prefix",
+			[]string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"},
+			map[string]interface{}{"ss58Format": 2},
+			true,
+		},
+		{
+			"ignores hex account IDs",
+			[]string{substrateTestAddr1},
+			nil,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, err := createSubstrateManager(subscriber.WS, store.Subscription{
+				Substrate: store.SubstrateSubscription{AccountIds: tt.accountIds},
+			}, nil)
+			require.NoError(t, err)
+			sm.caller = mockCaller(func(msg jsonrpcMessage) (interface{}, error) {
+				if msg.Method != "system_properties" || tt.properties == nil {
+					return nil, errors.New("unexpected method")
+				}
+				return tt.properties, nil
+			})
+
+			err = sm.checkSS58Prefixes()
+			if tt.wantErr {
+				assert.IsType(t, ValidationError{}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
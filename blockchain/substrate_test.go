@@ -45,20 +45,42 @@ func TestCreateSubstrateManager(t *testing.T) {
 			false,
 		},
 		{
-			"disregards invalid addresses",
+			"adds valid SS58 addresses",
+			args{
+				subscriber.WS,
+				store.SubstrateSubscription{
+					AccountIds: []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY", substrateTestAddr2},
+				},
+			},
+			[]types.Address{addr1, addr2},
+			false,
+		},
+		{
+			"fails on invalid addresses",
 			args{
 				subscriber.WS,
 				store.SubstrateSubscription{
 					AccountIds: []string{"not a valid address", substrateTestAddr2},
 				},
 			},
-			[]types.Address{addr2},
+			nil,
+			true,
+		},
+		{
+			"accepts RPC connections",
+			args{
+				subscriber.RPC,
+				store.SubstrateSubscription{
+					AccountIds: []string{substrateTestAddr1},
+				},
+			},
+			[]types.Address{addr1},
 			false,
 		},
 		{
 			"fails on invalid connection type",
 			args{
-				subscriber.RPC,
+				subscriber.Client,
 				store.SubstrateSubscription{
 					AccountIds: []string{substrateTestAddr1, substrateTestAddr2},
 				},
@@ -69,7 +91,7 @@ func TestCreateSubstrateManager(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := createSubstrateManager(tt.args.t, store.Subscription{Substrate: tt.args.sub})
+			got, err := createSubstrateManager(tt.args.t, store.Subscription{Substrate: tt.args.sub}, nil)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
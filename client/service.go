@@ -16,6 +16,14 @@ import (
 	"time"
 )
 
+// The statuses of the subscription of a job.
+const (
+	JobActive = "active"
+	// JobPaused is the status of a subscription paused by its
+	// chain, e.g. after its operator was unregistered.
+	JobPaused = "paused"
+)
+
 type storeInterface interface {
 	DeleteAllEndpointsExcept(names []string) error
 	LoadSubscriptions() ([]store.Subscription, error)
@@ -25,6 +33,14 @@ type storeInterface interface {
 	SaveSubscription(arg *store.Subscription) error
 	DeleteSubscription(subscription *store.Subscription) error
 	SaveEndpoint(e *store.Endpoint) error
+	LoadBlockCursor(job string) (*store.BlockCursor, error)
+	SaveBlockCursor(cursor *store.BlockCursor) error
+	LoadFactoryAddresses(job string) ([]string, error)
+	SaveFactoryAddress(job, address string) error
+	LoadDeviationState(job string) (*store.DeviationState, error)
+	SaveDeviationState(state *store.DeviationState) error
+	LoadSubscriptionState(job string) (*store.SubscriptionState, error)
+	SaveSubscriptionState(state *store.SubscriptionState) error
 }
 
 // startService runs the Service in the background and gracefully stops when a
@@ -153,7 +169,7 @@ func (srv *Service) getAndTestSubscription(sub *store.Subscription) (subscriber.
 	}
 	sub.Endpoint = endpoint
 
-	iSubscriber, err := getSubscriber(*sub)
+	iSubscriber, err := getSubscriber(*sub, srv.store)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +251,8 @@ func (srv *Service) subscribe(sub *store.Subscription, iSubscriber subscriber.IS
 }
 
 // SaveSubscription tests, stores and subscribes to the store.Subscription
-// provided.
+// provided. If the chain rejects its params when tested, the cause of the
+// error returned is a blockchain.ValidationError.
 func (srv *Service) SaveSubscription(arg *store.Subscription) error {
 	sub, err := srv.getAndTestSubscription(arg)
 	if err != nil {
@@ -269,6 +286,23 @@ func (srv *Service) DeleteJob(jobid string) error {
 	return srv.store.DeleteSubscription(sub)
 }
 
+// GetJobStatus returns the status of the subscription of the job:
+// JobPaused if its chain paused it, or JobActive otherwise.
+func (srv *Service) GetJobStatus(jobid string) (string, error) {
+	if _, err := srv.store.LoadSubscription(jobid); err != nil {
+		return "", err
+	}
+
+	state, err := srv.store.LoadSubscriptionState(jobid)
+	if err != nil {
+		return "", err
+	}
+	if state != nil && state.Paused {
+		return JobPaused, nil
+	}
+	return JobActive, nil
+}
+
 // GetEndpoint returns an instance of store.Endpoint that
 // matches the endpoint name provided.
 func (srv *Service) GetEndpoint(name string) (*store.Endpoint, error) {
@@ -290,7 +324,7 @@ func (srv *Service) SaveEndpoint(e *store.Endpoint) error {
 	return srv.store.SaveEndpoint(e)
 }
 
-func getSubscriber(sub store.Subscription) (subscriber.ISubscriber, error) {
+func getSubscriber(sub store.Subscription, db blockchain.StateStorer) (subscriber.ISubscriber, error) {
 	connType, err := blockchain.GetConnectionType(sub.Endpoint)
 	if err != nil {
 		return nil, err
@@ -300,7 +334,7 @@ func getSubscriber(sub store.Subscription) (subscriber.ISubscriber, error) {
 		return blockchain.CreateClientManager(sub)
 	}
 
-	manager, err := blockchain.CreateJsonManager(connType, sub)
+	manager, err := blockchain.CreateJsonManager(connType, sub, db)
 	if err != nil {
 		return nil, err
 	}
@@ -17,6 +17,7 @@ type storeClientFailer struct {
 	closeError   error
 	deleteError  error
 	endpointName string
+	state        *store.SubscriptionState
 }
 
 func (s storeClientFailer) DeleteAllEndpointsExcept([]string) error {
@@ -51,6 +52,38 @@ func (s storeClientFailer) SaveEndpoint(*store.Endpoint) error {
 	return s.error
 }
 
+func (s storeClientFailer) LoadBlockCursor(string) (*store.BlockCursor, error) {
+	return nil, s.error
+}
+
+func (s storeClientFailer) SaveBlockCursor(*store.BlockCursor) error {
+	return s.error
+}
+
+func (s storeClientFailer) LoadFactoryAddresses(string) ([]string, error) {
+	return nil, s.error
+}
+
+func (s storeClientFailer) SaveFactoryAddress(string, string) error {
+	return s.error
+}
+
+func (s storeClientFailer) LoadDeviationState(string) (*store.DeviationState, error) {
+	return nil, s.error
+}
+
+func (s storeClientFailer) SaveDeviationState(*store.DeviationState) error {
+	return s.error
+}
+
+func (s storeClientFailer) LoadSubscriptionState(string) (*store.SubscriptionState, error) {
+	return s.state, s.error
+}
+
+func (s storeClientFailer) SaveSubscriptionState(*store.SubscriptionState) error {
+	return s.error
+}
+
 type mockSubscription struct {
 	error error
 }
@@ -63,7 +96,7 @@ func Test_getSubscriber(t *testing.T) {
 			Url:  "ws://localhost",
 			Type: blockchain.ETH,
 		},
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	ethRpcManager, err := blockchain.CreateJsonManager(subscriber.RPC, store.Subscription{
@@ -72,7 +105,7 @@ func Test_getSubscriber(t *testing.T) {
 			Type: blockchain.ETH,
 		},
 		Ethereum: store.EthSubscription{},
-	})
+	}, nil)
 	require.NoError(t, err)
 
 	type args struct {
@@ -137,7 +170,7 @@ func Test_getSubscriber(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getSubscriber(tt.args.sub)
+			got, err := getSubscriber(tt.args.sub, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getSubscriber() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -256,6 +289,47 @@ func Test_Service_DeleteJob(t *testing.T) {
 	}
 }
 
+func Test_Service_GetJobStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   storeInterface
+		want    string
+		wantErr bool
+	}{
+		{
+			"active without state",
+			storeClientFailer{},
+			JobActive,
+			false,
+		},
+		{
+			"paused by its chain",
+			storeClientFailer{state: &store.SubscriptionState{Paused: true}},
+			JobPaused,
+			false,
+		},
+		{
+			"fails on non-existent job",
+			storeClientFailer{error: errors.New("record not found")},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &Service{store: tt.store}
+			got, err := srv.GetJobStatus("testJob")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetJobStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetJobStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_Service_GetEndpoint(t *testing.T) {
 	type fields struct {
 		clNode        chainlink.Node
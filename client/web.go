@@ -19,6 +19,7 @@ const (
 type subscriptionStorer interface {
 	SaveSubscription(sub *store.Subscription) error
 	DeleteJob(jobid string) error
+	GetJobStatus(jobid string) (string, error)
 	GetEndpoint(name string) (*store.Endpoint, error)
 	SaveEndpoint(endpoint *store.Endpoint) error
 }
@@ -75,6 +76,7 @@ func (srv *HttpService) createRouter() {
 	auth.Use(authenticate(srv.AccessKey, srv.Secret))
 	{
 		auth.POST("/jobs", srv.CreateSubscription)
+		auth.GET("/jobs/:jobid", srv.ShowSubscription)
 		auth.DELETE("/jobs/:jobid", srv.DeleteSubscription)
 		auth.POST("/config", srv.CreateEndpoint)
 	}
@@ -102,10 +104,10 @@ type CreateSubscriptionReq struct {
 	Params blockchain.Params `json:"params"`
 }
 
-func validateRequest(t *CreateSubscriptionReq, endpointType string) error {
+func validateRequest(t *CreateSubscriptionReq, endpoint store.Endpoint) error {
 	validations := append([]int{
 		len(t.JobID),
-	}, blockchain.GetValidations(endpointType, t.Params)...)
+	}, blockchain.GetValidations(endpoint.Type, t.Params)...)
 
 	for _, v := range validations {
 		if v < 1 {
@@ -113,7 +115,7 @@ func validateRequest(t *CreateSubscriptionReq, endpointType string) error {
 		}
 	}
 
-	return nil
+	return blockchain.ValidateParams(endpoint, t.Params)
 }
 
 type resp struct {
@@ -143,9 +145,9 @@ func (srv *HttpService) CreateSubscription(c *gin.Context) {
 		return
 	}
 
-	if err := validateRequest(&req, endpoint.Type); err != nil {
+	if err := validateRequest(&req, *endpoint); err != nil {
 		log.Println(err)
-		c.JSON(http.StatusBadRequest, nil)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -160,6 +162,11 @@ func (srv *HttpService) CreateSubscription(c *gin.Context) {
 
 	if err := srv.Store.SaveSubscription(sub); err != nil {
 		log.Println(err)
+		// The chain rejected the params when testing the subscription
+		if _, ok := errors.Cause(err).(blockchain.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, nil)
 		return
 	}
@@ -167,6 +174,25 @@ func (srv *HttpService) CreateSubscription(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp{ID: sub.ReferenceId})
 }
 
+type jobStatusResp struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// ShowSubscription returns the status of the subscription
+// of the job with the jobid provided as parameter.
+func (srv *HttpService) ShowSubscription(c *gin.Context) {
+	jobid := c.Param("jobid")
+	status, err := srv.Store.GetJobStatus(jobid)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusNotFound, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobStatusResp{ID: jobid, Status: status})
+}
+
 // DeleteSubscription deletes any job with the jobid
 // provided as parameter in the request.
 func (srv *HttpService) DeleteSubscription(c *gin.Context) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/pkg/errors"
+	"github.com/smartcontractkit/external-initiator/blockchain"
 	"github.com/smartcontractkit/external-initiator/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +27,10 @@ func (s storeFailer) DeleteJob(string) error {
 	return s.error
 }
 
+func (s storeFailer) GetJobStatus(string) (string, error) {
+	return JobPaused, s.error
+}
+
 func (s storeFailer) GetEndpoint(string) (*store.Endpoint, error) {
 	return s.endpoint, s.endpointError
 }
@@ -35,12 +40,7 @@ func (s storeFailer) SaveEndpoint(*store.Endpoint) error {
 }
 
 func generateCreateSubscriptionReq(id, endpoint string, addresses, topics, accountIds []string) CreateSubscriptionReq {
-	params := struct {
-		Endpoint   string   `json:"endpoint"`
-		Addresses  []string `json:"addresses"`
-		Topics     []string `json:"eventTopics"`
-		AccountIDs []string `json:"accountIds"`
-	}{
+	params := blockchain.Params{
 		Endpoint:   endpoint,
 		Addresses:  addresses,
 		Topics:     topics,
@@ -73,6 +73,18 @@ func TestConfigController(t *testing.T) {
 			storeFailer{nil, &store.Endpoint{Name: "eth-mainnet", Type: "ethereum"}, nil},
 			http.StatusBadRequest,
 		},
+		{
+			"Invalid Substrate account ID",
+			generateCreateSubscriptionReq("id", "substrate", nil, nil, []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ"}),
+			storeFailer{nil, &store.Endpoint{Name: "substrate", Type: "substrate"}, nil},
+			http.StatusBadRequest,
+		},
+		{
+			"Invalid Ethereum config",
+			CreateSubscriptionReq{JobID: "id", Params: blockchain.Params{Endpoint: "eth-mainnet", Addresses: []string{"0x123"}, Kind: "unknown"}},
+			storeFailer{nil, &store.Endpoint{Name: "eth-mainnet", Type: "ethereum", Url: "http://localhost:8545"}, nil},
+			http.StatusBadRequest,
+		},
 		{
 			"Decode failed",
 			"bad json format",
@@ -85,6 +97,12 @@ func TestConfigController(t *testing.T) {
 			storeFailer{errors.New("failed save"), &store.Endpoint{Name: "eth-mainnet", Type: "ethereum"}, nil},
 			http.StatusInternalServerError,
 		},
+		{
+			"Rejected by the chain",
+			generateCreateSubscriptionReq("id", "substrate", nil, nil, []string{"5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"}),
+			storeFailer{errors.Wrap(blockchain.ValidationError{Err: errors.New("other network")}, "Failed testing subscriber"), &store.Endpoint{Name: "substrate", Type: "substrate"}, nil},
+			http.StatusBadRequest,
+		},
 		{
 			"Endpoint does not exist",
 			generateCreateSubscriptionReq("id", "doesnt-exist", []string{"0x123"}, []string{"0x123"}, []string{"0x123"}),
@@ -175,6 +193,51 @@ func TestDeleteController(t *testing.T) {
 	}
 }
 
+func TestShowController(t *testing.T) {
+	tests := []struct {
+		Name       string
+		Jobid      string
+		App        subscriptionStorer
+		StatusCode int
+	}{
+		{
+			"Show success",
+			"test",
+			storeFailer{nil, nil, nil},
+			http.StatusOK,
+		},
+		{
+			"Job does not exist",
+			"test",
+			storeFailer{errors.New("record not found"), nil, nil},
+			http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Log(test.Name)
+		srv := &HttpService{
+			Store: test.App,
+		}
+		srv.createRouter()
+
+		req := httptest.NewRequest("GET", "/jobs/"+test.Jobid, nil)
+
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		assert.Equal(t, test.StatusCode, w.Code)
+
+		if w.Code == http.StatusNotFound {
+			// Do not expect JSON response
+			continue
+		}
+
+		var respJSON map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &respJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"id": "test", "status": JobPaused}, respJSON)
+	}
+}
+
 func TestHealthController(t *testing.T) {
 	tests := []struct {
 		Name       string
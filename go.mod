@@ -24,7 +24,8 @@ require (
 	github.com/spf13/viper v1.2.1
 	github.com/stretchr/testify v1.3.0
 	github.com/tidwall/gjson v1.3.5
-	golang.org/x/crypto v0.0.0-20190404164418-38d8ce5564a5 // indirect
+	github.com/ugorji/go v1.1.4
+	golang.org/x/crypto v0.0.0-20190404164418-38d8ce5564a5
 	golang.org/x/net v0.0.0-20190912160710-24e19bdeb0f2 // indirect
 	golang.org/x/sys v0.0.0-20190912141932-bc967efca4b8 // indirect
 	gopkg.in/gormigrate.v1 v1.6.0
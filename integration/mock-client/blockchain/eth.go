@@ -19,6 +19,10 @@ func HandleEthRequest(conn string, msg JsonrpcMessage) ([]JsonrpcMessage, error)
 			return handleEthBlockNumber(msg)
 		case "eth_getLogs":
 			return handleEthGetLogs(msg)
+		case "trace_filter":
+			return handleTraceFilter(msg)
+		case "debug_traceBlockByNumber":
+			return handleDebugTraceBlockByNumber(msg)
 		}
 	}
 
@@ -0,0 +1,104 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// tracedAddress is the contract called in the canned traces,
+// matching the address used by the integration tests.
+const tracedAddress = "0x2ad9b7b9386c2f45223ddfc4a4d81c2957bae19a"
+
+// cannedTraces are the "trace_filter" traces of a transaction where
+// an account calls a contract, which calls "transfer(address,uint256)"
+// on tracedAddress. The transaction is in block 0, which is the block
+// number returned by the mock client.
+var cannedTraces = fmt.Sprintf(`[
+  {
+    "action": {
+      "callType": "call",
+      "from": "0x0000000000000000000000000000000000000001",
+      "to": "0x00000000000000000000000000000000000000aa",
+      "input": "0x12345678",
+      "value": "0x0"
+    },
+    "blockNumber": 0,
+    "transactionHash": "0x00000000000000000000000000000000000000000000000000000000000000f1",
+    "traceAddress": [],
+    "type": "call"
+  },
+  {
+    "action": {
+      "callType": "call",
+      "from": "0x00000000000000000000000000000000000000aa",
+      "to": "%s",
+      "input": "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000064",
+      "value": "0x0"
+    },
+    "blockNumber": 0,
+    "transactionHash": "0x00000000000000000000000000000000000000000000000000000000000000f1",
+    "traceAddress": [0],
+    "type": "call"
+  }
+]`, tracedAddress)
+
+// cannedCallTrace is the same transaction as
+// cannedTraces, as traced by the callTracer of geth.
+var cannedCallTrace = fmt.Sprintf(`[
+  {
+    "txHash": "0x00000000000000000000000000000000000000000000000000000000000000f1",
+    "result": {
+      "type": "CALL",
+      "from": "0x0000000000000000000000000000000000000001",
+      "to": "0x00000000000000000000000000000000000000aa",
+      "input": "0x12345678",
+      "value": "0x0",
+      "calls": [
+        {
+          "type": "CALL",
+          "from": "0x00000000000000000000000000000000000000aa",
+          "to": "%s",
+          "input": "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000064",
+          "value": "0x0"
+        }
+      ]
+    }
+  }
+]`, tracedAddress)
+
+func handleTraceFilter(msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	var filters []map[string]interface{}
+	if err := json.Unmarshal(msg.Params, &filters); err != nil {
+		return nil, err
+	}
+	if len(filters) != 1 {
+		return nil, errors.New(fmt.Sprintf("Expected exactly 1 filter in request, got %d", len(filters)))
+	}
+
+	return []JsonrpcMessage{
+		{
+			Version: "2.0",
+			ID:      msg.ID,
+			Result:  []byte(cannedTraces),
+		},
+	}, nil
+}
+
+func handleDebugTraceBlockByNumber(msg JsonrpcMessage) ([]JsonrpcMessage, error) {
+	var params []interface{}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, err
+	}
+	if len(params) != 2 {
+		return nil, errors.New(fmt.Sprint("possibly incorrect length of params array:", len(params)))
+	}
+
+	return []JsonrpcMessage{
+		{
+			Version: "2.0",
+			ID:      msg.ID,
+			Result:  []byte(cannedCallTrace),
+		},
+	}, nil
+}
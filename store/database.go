@@ -5,11 +5,14 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/pkg/errors"
 	"github.com/smartcontractkit/external-initiator/store/migrations"
+	"io"
+	"time"
 )
 
 const sqlDialect = "postgres"
@@ -34,6 +37,11 @@ func (arr *SQLStringArray) Scan(src interface{}) error {
 	buf := bytes.NewBufferString(str)
 	r := csv.NewReader(buf)
 	ret, err := r.Read()
+	if err == io.EOF {
+		// An empty array is stored as an empty string
+		*arr = nil
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "badly formatted csv string array")
 	}
@@ -53,6 +61,76 @@ func (arr SQLStringArray) Value() (driver.Value, error) {
 	return buf.String(), nil
 }
 
+// SQLStringMatrix is a nested string array stored in the database as JSON.
+type SQLStringMatrix [][]string
+
+// Scan implements the sql Scanner interface.
+func (m *SQLStringMatrix) Scan(src interface{}) error {
+	var bz []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		bz = []byte(v)
+	case []byte:
+		bz = v
+	default:
+		return errors.New("failed to scan StringMatrix")
+	}
+
+	var ret [][]string
+	if err := json.Unmarshal(bz, &ret); err != nil {
+		return errors.Wrap(err, "badly formatted json string matrix")
+	}
+	*m = ret
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (m SQLStringMatrix) Value() (driver.Value, error) {
+	bz, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "json encoding of string matrix")
+	}
+	return string(bz), nil
+}
+
+// SQLStringMap is a string map stored in the database as JSON.
+type SQLStringMap map[string]string
+
+// Scan implements the sql Scanner interface.
+func (m *SQLStringMap) Scan(src interface{}) error {
+	var bz []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		bz = []byte(v)
+	case []byte:
+		bz = v
+	default:
+		return errors.New("failed to scan StringMap")
+	}
+
+	var ret map[string]string
+	if err := json.Unmarshal(bz, &ret); err != nil {
+		return errors.Wrap(err, "badly formatted json string map")
+	}
+	*m = ret
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (m SQLStringMap) Value() (driver.Value, error) {
+	bz, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "json encoding of string map")
+	}
+	return string(bz), nil
+}
+
 // Client holds a connection to the database.
 type Client struct {
 	db *gorm.DB
@@ -156,9 +234,112 @@ func (client Client) SaveSubscription(sub *Subscription) error {
 	return client.db.Create(sub).Error
 }
 
-// DeleteSubscription will soft-delete the subscription provided.
+// DeleteSubscription will soft-delete the subscription provided,
+// and remove any block cursor stored for its job.
 func (client Client) DeleteSubscription(sub *Subscription) error {
-	return client.db.Delete(sub).Error
+	if err := client.db.Delete(sub).Error; err != nil {
+		return err
+	}
+	if err := client.db.Unscoped().Where("job = ?", sub.Job).Delete(BlockCursor{}).Error; err != nil {
+		return err
+	}
+	if err := client.db.Unscoped().Where("job = ?", sub.Job).Delete(FactoryAddress{}).Error; err != nil {
+		return err
+	}
+	if err := client.db.Unscoped().Where("job = ?", sub.Job).Delete(DeviationState{}).Error; err != nil {
+		return err
+	}
+	return client.db.Unscoped().Where("job = ?", sub.Job).Delete(SubscriptionState{}).Error
+}
+
+// LoadBlockCursor will return the block cursor stored for the
+// job provided, or nil if no cursor has been stored yet.
+func (client Client) LoadBlockCursor(job string) (*BlockCursor, error) {
+	var cursor BlockCursor
+	err := client.db.Where(BlockCursor{Job: job}).First(&cursor).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SaveBlockCursor will store the block cursor in the database
+// and overwrite any previous cursor for the same job.
+func (client Client) SaveBlockCursor(cursor *BlockCursor) error {
+	return client.db.Where(BlockCursor{Job: cursor.Job}).Assign(map[string]interface{}{
+		"block_number": cursor.BlockNumber,
+		"log_index":    cursor.LogIndex,
+	}).FirstOrCreate(cursor).Error
+}
+
+// LoadFactoryAddresses will return the addresses discovered
+// from the factory followed by the job provided.
+func (client Client) LoadFactoryAddresses(job string) ([]string, error) {
+	var addresses []FactoryAddress
+	if err := client.db.Where(FactoryAddress{Job: job}).Order("id").Find(&addresses).Error; err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, a := range addresses {
+		result = append(result, a.Address)
+	}
+	return result, nil
+}
+
+// SaveFactoryAddress will store an address discovered from the
+// factory followed by the job, if it has not been stored yet.
+func (client Client) SaveFactoryAddress(job, address string) error {
+	return client.db.Where(FactoryAddress{Job: job, Address: address}).FirstOrCreate(&FactoryAddress{}).Error
+}
+
+// LoadDeviationState will return the last value forwarded for the
+// job provided, or nil if no value has been forwarded yet.
+func (client Client) LoadDeviationState(job string) (*DeviationState, error) {
+	var state DeviationState
+	err := client.db.Where(DeviationState{Job: job}).First(&state).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveDeviationState will store the last value forwarded for
+// the job, and overwrite any previous value for the same job.
+func (client Client) SaveDeviationState(state *DeviationState) error {
+	return client.db.Where(DeviationState{Job: state.Job}).Assign(map[string]interface{}{
+		"value":        state.Value,
+		"forwarded_at": state.ForwardedAt,
+	}).FirstOrCreate(state).Error
+}
+
+// LoadSubscriptionState will return the state of the subscription
+// of the job provided, or nil if no state has been stored yet.
+func (client Client) LoadSubscriptionState(job string) (*SubscriptionState, error) {
+	var state SubscriptionState
+	err := client.db.Where(SubscriptionState{Job: job}).First(&state).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveSubscriptionState will store the state of the subscription of
+// the job, and overwrite any previous state for the same job.
+func (client Client) SaveSubscriptionState(state *SubscriptionState) error {
+	return client.db.Where(SubscriptionState{Job: state.Job}).Assign(map[string]interface{}{
+		"paused":          state.Paused,
+		"killed_requests": state.KilledRequests,
+	}).FirstOrCreate(state).Error
 }
 
 // LoadEndpoint will return the endpoint in the database with
@@ -247,6 +428,31 @@ type EthSubscription struct {
 	SubscriptionId uint
 	Addresses      SQLStringArray
 	Topics         SQLStringArray
+	TopicFilter    SQLStringMatrix
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+	Strategy       string
+	Function       string `gorm:"type:text"`
+	Args           SQLStringArray
+	Threshold      string
+	Cooldown       uint64
+	Receipts       bool
+	RequireSuccess bool
+	TxFrom         SQLStringArray
+	MinValue       string
+	BlockHeaders   bool
+	Factory        string
+	FactoryEvent   string `gorm:"type:text"`
+	FactoryChild   string
+	Selectors      SQLStringArray
+	Deviation      string
+	Heartbeat      uint64
+	Field          string
+	Token          string
+	TransferFrom   SQLStringArray
+	TransferTo     SQLStringArray
+	MinAmount      string
 }
 
 type TezosSubscription struct {
@@ -259,4 +465,48 @@ type SubstrateSubscription struct {
 	gorm.Model
 	SubscriptionId uint
 	AccountIds     SQLStringArray
+	Pallet         string
+	Event          string
+	Filter         SQLStringMap
+	Types          SQLStringMap
+	Finalized      bool
+}
+
+// BlockCursor holds the progress of a subscription through the
+// chain, so that it can resume where it left off after a restart.
+type BlockCursor struct {
+	gorm.Model
+	Job string
+	// BlockNumber is the last block that has been fully processed.
+	BlockNumber uint64
+	// LogIndex is the index of the next log to process in the
+	// block following BlockNumber, if it was partially processed.
+	LogIndex uint64
+}
+
+// DeviationState holds the last value forwarded for a subscription
+// with a deviation trigger, and when it was forwarded.
+type DeviationState struct {
+	gorm.Model
+	Job         string
+	Value       string
+	ForwardedAt time.Time
+}
+
+// SubscriptionState holds the state of a subscription controlled
+// by its chain: whether it is paused because its operator was
+// unregistered, and the requests that were killed.
+type SubscriptionState struct {
+	gorm.Model
+	Job            string
+	Paused         bool
+	KilledRequests SQLStringArray
+}
+
+// FactoryAddress is the address of a contract deployed by the
+// factory a subscription follows.
+type FactoryAddress struct {
+	gorm.Model
+	Job     string
+	Address string
 }
@@ -108,6 +108,13 @@ func TestSQLStringArray_Scan(t *testing.T) {
 			false,
 			[]string{"abc", "123"},
 		},
+		{
+			"empty string gives empty array",
+			SQLStringArray{},
+			args{""},
+			false,
+			nil,
+		},
 		{
 			"fails on invalid list",
 			SQLStringArray{},
@@ -164,6 +171,108 @@ func TestSQLStringArray_Value(t *testing.T) {
 	}
 }
 
+func TestSQLStringMatrix_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		wantErr bool
+		result  SQLStringMatrix
+	}{
+		{
+			"parses json string",
+			`[["abc"],null,["123","456"]]`,
+			false,
+			SQLStringMatrix{{"abc"}, nil, {"123", "456"}},
+		},
+		{
+			"parses json bytes",
+			[]byte(`[["abc"]]`),
+			false,
+			SQLStringMatrix{{"abc"}},
+		},
+		{
+			"null gives nil",
+			nil,
+			false,
+			nil,
+		},
+		{
+			"fails on invalid json",
+			`[["abc"]`,
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m SQLStringMatrix
+			if err := m.Scan(tt.src); (err != nil) != tt.wantErr {
+				t.Errorf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.result, m)
+			}
+		})
+	}
+}
+
+func TestSQLStringMatrix_Value(t *testing.T) {
+	got, err := SQLStringMatrix{{"abc"}, nil}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, `[["abc"],null]`, got)
+}
+
+func TestSQLStringMap_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		wantErr bool
+		result  SQLStringMap
+	}{
+		{
+			"parses json string",
+			`{"arg0":"abc","arg1":"123"}`,
+			false,
+			SQLStringMap{"arg0": "abc", "arg1": "123"},
+		},
+		{
+			"parses json bytes",
+			[]byte(`{"arg0":"abc"}`),
+			false,
+			SQLStringMap{"arg0": "abc"},
+		},
+		{
+			"null gives nil",
+			nil,
+			false,
+			nil,
+		},
+		{
+			"fails on invalid json",
+			`{"arg0":`,
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m SQLStringMap
+			if err := m.Scan(tt.src); (err != nil) != tt.wantErr {
+				t.Errorf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.result, m)
+			}
+		})
+	}
+}
+
+func TestSQLStringMap_Value(t *testing.T) {
+	got, err := SQLStringMap{"arg0": "abc"}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, `{"arg0":"abc"}`, got)
+}
+
 func TestClient_SaveSubscription(t *testing.T) {
 	config := Config{
 		DatabaseURL: os.Getenv("DATABASE_URL"),
@@ -432,3 +541,150 @@ func TestClient_DeleteAllEndpointsExcept(t *testing.T) {
 	_, err = db.LoadEndpoint(newEndpoint.Name)
 	assert.Error(t, err)
 }
+
+func TestClient_BlockCursor(t *testing.T) {
+	config := Config{
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+	}
+
+	cleanupDB := prepareTestDB(t, &config)
+	defer cleanupDB()
+	db, err := ConnectToDb(config.DatabaseURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	cursor, err := db.LoadBlockCursor("test123")
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+
+	err = db.SaveBlockCursor(&BlockCursor{Job: "test123", BlockNumber: 10, LogIndex: 2})
+	require.NoError(t, err)
+	err = db.SaveBlockCursor(&BlockCursor{Job: "test123", BlockNumber: 12})
+	require.NoError(t, err)
+
+	cursor, err = db.LoadBlockCursor("test123")
+	require.NoError(t, err)
+	require.NotNil(t, cursor)
+	assert.Equal(t, uint64(12), cursor.BlockNumber)
+	assert.Equal(t, uint64(0), cursor.LogIndex)
+
+	sub := Subscription{ReferenceId: "abc", Job: "test123", EndpointName: "test"}
+	err = db.SaveSubscription(&sub)
+	require.NoError(t, err)
+	err = db.DeleteSubscription(&sub)
+	require.NoError(t, err)
+
+	cursor, err = db.LoadBlockCursor("test123")
+	require.NoError(t, err)
+	assert.Nil(t, cursor)
+}
+
+func TestClient_FactoryAddresses(t *testing.T) {
+	config := Config{
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+	}
+
+	cleanupDB := prepareTestDB(t, &config)
+	defer cleanupDB()
+	db, err := ConnectToDb(config.DatabaseURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	addresses, err := db.LoadFactoryAddresses("test123")
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	require.NoError(t, db.SaveFactoryAddress("test123", "0x0000000000000000000000000000000000000001"))
+	require.NoError(t, db.SaveFactoryAddress("test123", "0x0000000000000000000000000000000000000002"))
+	require.NoError(t, db.SaveFactoryAddress("test123", "0x0000000000000000000000000000000000000001"))
+	require.NoError(t, db.SaveFactoryAddress("other", "0x0000000000000000000000000000000000000003"))
+
+	addresses, err = db.LoadFactoryAddresses("test123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002"}, addresses)
+
+	sub := Subscription{ReferenceId: "abc", Job: "test123", EndpointName: "test"}
+	err = db.SaveSubscription(&sub)
+	require.NoError(t, err)
+	err = db.DeleteSubscription(&sub)
+	require.NoError(t, err)
+
+	addresses, err = db.LoadFactoryAddresses("test123")
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+}
+
+func TestClient_DeviationState(t *testing.T) {
+	config := Config{
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+	}
+
+	cleanupDB := prepareTestDB(t, &config)
+	defer cleanupDB()
+	db, err := ConnectToDb(config.DatabaseURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	state, err := db.LoadDeviationState("test123")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	forwardedAt := time.Unix(1591037782, 0).UTC()
+	err = db.SaveDeviationState(&DeviationState{Job: "test123", Value: "100", ForwardedAt: forwardedAt.Add(-time.Minute)})
+	require.NoError(t, err)
+	err = db.SaveDeviationState(&DeviationState{Job: "test123", Value: "102", ForwardedAt: forwardedAt})
+	require.NoError(t, err)
+
+	state, err = db.LoadDeviationState("test123")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, "102", state.Value)
+	assert.True(t, forwardedAt.Equal(state.ForwardedAt))
+
+	sub := Subscription{ReferenceId: "abc", Job: "test123", EndpointName: "test"}
+	err = db.SaveSubscription(&sub)
+	require.NoError(t, err)
+	err = db.DeleteSubscription(&sub)
+	require.NoError(t, err)
+
+	state, err = db.LoadDeviationState("test123")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestClient_SubscriptionState(t *testing.T) {
+	config := Config{
+		DatabaseURL: os.Getenv("DATABASE_URL"),
+	}
+
+	cleanupDB := prepareTestDB(t, &config)
+	defer cleanupDB()
+	db, err := ConnectToDb(config.DatabaseURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	state, err := db.LoadSubscriptionState("test123")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	err = db.SaveSubscriptionState(&SubscriptionState{Job: "test123", KilledRequests: SQLStringArray{"1"}})
+	require.NoError(t, err)
+	err = db.SaveSubscriptionState(&SubscriptionState{Job: "test123", Paused: true, KilledRequests: SQLStringArray{"1", "2"}})
+	require.NoError(t, err)
+
+	state, err = db.LoadSubscriptionState("test123")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.True(t, state.Paused)
+	assert.Equal(t, SQLStringArray{"1", "2"}, state.KilledRequests)
+
+	sub := Subscription{ReferenceId: "abc", Job: "test123", EndpointName: "test"}
+	err = db.SaveSubscription(&sub)
+	require.NoError(t, err)
+	err = db.DeleteSubscription(&sub)
+	require.NoError(t, err)
+
+	state, err = db.LoadSubscriptionState("test123")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
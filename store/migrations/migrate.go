@@ -7,6 +7,23 @@ import (
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1576509489"
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1576783801"
 	"github.com/smartcontractkit/external-initiator/store/migrations/migration1582671289"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1583931214"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1584454872"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1585039217"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1585648346"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1586163522"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1586781409"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1587390675"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1587998127"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1588604291"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1589212846"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1589823960"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1590428513"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1591037782"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1591641155"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1592246391"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1592852718"
+	"github.com/smartcontractkit/external-initiator/store/migrations/migration1593457036"
 	"gopkg.in/gormigrate.v1"
 )
 
@@ -36,6 +53,91 @@ func Migrate(db *gorm.DB) error {
 			Migrate:  migration1582671289.Migrate,
 			Rollback: migration1582671289.Rollback,
 		},
+		{
+			ID:       "1583931214",
+			Migrate:  migration1583931214.Migrate,
+			Rollback: migration1583931214.Rollback,
+		},
+		{
+			ID:       "1584454872",
+			Migrate:  migration1584454872.Migrate,
+			Rollback: migration1584454872.Rollback,
+		},
+		{
+			ID:       "1585039217",
+			Migrate:  migration1585039217.Migrate,
+			Rollback: migration1585039217.Rollback,
+		},
+		{
+			ID:       "1585648346",
+			Migrate:  migration1585648346.Migrate,
+			Rollback: migration1585648346.Rollback,
+		},
+		{
+			ID:       "1586163522",
+			Migrate:  migration1586163522.Migrate,
+			Rollback: migration1586163522.Rollback,
+		},
+		{
+			ID:       "1586781409",
+			Migrate:  migration1586781409.Migrate,
+			Rollback: migration1586781409.Rollback,
+		},
+		{
+			ID:       "1587390675",
+			Migrate:  migration1587390675.Migrate,
+			Rollback: migration1587390675.Rollback,
+		},
+		{
+			ID:       "1587998127",
+			Migrate:  migration1587998127.Migrate,
+			Rollback: migration1587998127.Rollback,
+		},
+		{
+			ID:       "1588604291",
+			Migrate:  migration1588604291.Migrate,
+			Rollback: migration1588604291.Rollback,
+		},
+		{
+			ID:       "1589212846",
+			Migrate:  migration1589212846.Migrate,
+			Rollback: migration1589212846.Rollback,
+		},
+		{
+			ID:       "1589823960",
+			Migrate:  migration1589823960.Migrate,
+			Rollback: migration1589823960.Rollback,
+		},
+		{
+			ID:       "1590428513",
+			Migrate:  migration1590428513.Migrate,
+			Rollback: migration1590428513.Rollback,
+		},
+		{
+			ID:       "1591037782",
+			Migrate:  migration1591037782.Migrate,
+			Rollback: migration1591037782.Rollback,
+		},
+		{
+			ID:       "1591641155",
+			Migrate:  migration1591641155.Migrate,
+			Rollback: migration1591641155.Rollback,
+		},
+		{
+			ID:       "1592246391",
+			Migrate:  migration1592246391.Migrate,
+			Rollback: migration1592246391.Rollback,
+		},
+		{
+			ID:       "1592852718",
+			Migrate:  migration1592852718.Migrate,
+			Rollback: migration1592852718.Rollback,
+		},
+		{
+			ID:       "1593457036",
+			Migrate:  migration1593457036.Migrate,
+			Rollback: migration1593457036.Rollback,
+		},
 	}
 
 	m := gormigrate.New(db, &options, migrations)
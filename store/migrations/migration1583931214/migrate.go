@@ -0,0 +1,29 @@
+package migration1583931214
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	Confirmations  uint64
+}
+
+// Migrate adds the required number of block
+// confirmations to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&EthSubscription{}).DropColumn("confirmations").Error
+}
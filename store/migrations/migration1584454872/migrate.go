@@ -0,0 +1,28 @@
+package migration1584454872
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type BlockCursor struct {
+	gorm.Model
+	Job         string `gorm:"unique;not null"`
+	BlockNumber uint64 `gorm:"not null"`
+	LogIndex    uint64 `gorm:"not null"`
+}
+
+// Migrate creates the block_cursors table, used to
+// persist the progress of each subscription.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&BlockCursor{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate BlockCursor")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.DropTable("block_cursors").Error
+}
@@ -0,0 +1,30 @@
+package migration1585039217
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+}
+
+// Migrate adds the positional topic filter
+// to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&EthSubscription{}).DropColumn("topic_filter").Error
+}
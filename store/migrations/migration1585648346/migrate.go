@@ -0,0 +1,31 @@
+package migration1585648346
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+}
+
+// Migrate adds the event ABI used to decode
+// logs to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&EthSubscription{}).DropColumn("event_abi").Error
+}
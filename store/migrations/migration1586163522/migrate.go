@@ -0,0 +1,32 @@
+package migration1586163522
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+}
+
+// Migrate adds the kind of subscription,
+// e.g. "runlog", to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&EthSubscription{}).DropColumn("kind").Error
+}
@@ -0,0 +1,33 @@
+package migration1586781409
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+	Strategy       string
+}
+
+// Migrate adds the strategy used to fetch
+// logs to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&EthSubscription{}).DropColumn("strategy").Error
+}
@@ -0,0 +1,65 @@
+package migration1589823960
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+	Strategy       string
+	Function       string `gorm:"type:text"`
+	Args           string
+	Threshold      string
+	Cooldown       uint64
+	Receipts       bool
+	RequireSuccess bool
+	TxFrom         string
+	MinValue       string
+	BlockHeaders   bool
+	Factory        string
+	FactoryEvent   string `gorm:"type:text"`
+	FactoryChild   string
+}
+
+type FactoryAddress struct {
+	gorm.Model
+	Job     string `gorm:"not null;unique_index:idx_factory_addresses_job_address"`
+	Address string `gorm:"not null;unique_index:idx_factory_addresses_job_address"`
+}
+
+// Migrate adds the factory followed by Ethereum subscriptions,
+// and creates the factory_addresses table, used to persist
+// the addresses discovered from the factory.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	err = tx.AutoMigrate(&FactoryAddress{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate FactoryAddress")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	if err := tx.DropTable("factory_addresses").Error; err != nil {
+		return err
+	}
+	for _, column := range []string{"factory", "factory_event", "factory_child"} {
+		if err := tx.Model(&EthSubscription{}).DropColumn(column).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
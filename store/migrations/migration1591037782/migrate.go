@@ -0,0 +1,71 @@
+package migration1591037782
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+	Strategy       string
+	Function       string `gorm:"type:text"`
+	Args           string
+	Threshold      string
+	Cooldown       uint64
+	Receipts       bool
+	RequireSuccess bool
+	TxFrom         string
+	MinValue       string
+	BlockHeaders   bool
+	Factory        string
+	FactoryEvent   string `gorm:"type:text"`
+	FactoryChild   string
+	Selectors      string
+	Deviation      string
+	Heartbeat      uint64
+	Field          string
+}
+
+type DeviationState struct {
+	gorm.Model
+	Job         string `gorm:"unique;not null"`
+	Value       string `gorm:"not null"`
+	ForwardedAt time.Time
+}
+
+// Migrate adds the deviation trigger to Ethereum subscriptions,
+// and creates the deviation_states table, used to persist the
+// last value forwarded for each subscription.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	err = tx.AutoMigrate(&DeviationState{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate DeviationState")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	if err := tx.DropTable("deviation_states").Error; err != nil {
+		return err
+	}
+	for _, column := range []string{"deviation", "heartbeat", "field"} {
+		if err := tx.Model(&EthSubscription{}).DropColumn(column).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
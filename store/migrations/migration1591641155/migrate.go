@@ -0,0 +1,58 @@
+package migration1591641155
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type EthSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	Addresses      string
+	Topics         string
+	TopicFilter    string
+	Confirmations  uint64
+	EventABI       string `gorm:"type:text"`
+	Kind           string
+	Strategy       string
+	Function       string `gorm:"type:text"`
+	Args           string
+	Threshold      string
+	Cooldown       uint64
+	Receipts       bool
+	RequireSuccess bool
+	TxFrom         string
+	MinValue       string
+	BlockHeaders   bool
+	Factory        string
+	FactoryEvent   string `gorm:"type:text"`
+	FactoryChild   string
+	Selectors      string
+	Deviation      string
+	Heartbeat      uint64
+	Field          string
+	Token          string
+	TransferFrom   string
+	TransferTo     string
+	MinAmount      string
+}
+
+// Migrate adds the token transfer filter
+// to Ethereum subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&EthSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate EthSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	for _, column := range []string{"token", "transfer_from", "transfer_to", "min_amount"} {
+		if err := tx.Model(&EthSubscription{}).DropColumn(column).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package migration1592246391
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type SubstrateSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	AccountIds     string
+	Pallet         string
+	Event          string
+	Filter         string `gorm:"type:text"`
+	Types          string `gorm:"type:text"`
+}
+
+// Migrate adds the pallet and event matching
+// to Substrate subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&SubstrateSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate SubstrateSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	for _, column := range []string{"pallet", "event", "filter", "types"} {
+		if err := tx.Model(&SubstrateSubscription{}).DropColumn(column).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
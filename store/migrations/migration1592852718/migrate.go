@@ -0,0 +1,32 @@
+package migration1592852718
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type SubstrateSubscription struct {
+	gorm.Model
+	SubscriptionId uint
+	AccountIds     string
+	Pallet         string
+	Event          string
+	Filter         string `gorm:"type:text"`
+	Types          string `gorm:"type:text"`
+	Finalized      bool
+}
+
+// Migrate adds the finalized-only mode
+// to Substrate subscriptions.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&SubstrateSubscription{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate SubstrateSubscription")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.Model(&SubstrateSubscription{}).DropColumn("finalized").Error
+}
@@ -0,0 +1,28 @@
+package migration1593457036
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type SubscriptionState struct {
+	gorm.Model
+	Job            string `gorm:"unique;not null"`
+	Paused         bool
+	KilledRequests string `gorm:"type:text"`
+}
+
+// Migrate creates the subscription_states table, used to persist
+// the state of subscriptions controlled by their chain.
+func Migrate(tx *gorm.DB) error {
+	err := tx.AutoMigrate(&SubscriptionState{}).Error
+	if err != nil {
+		return errors.Wrap(err, "failed to auto migrate SubscriptionState")
+	}
+
+	return nil
+}
+
+func Rollback(tx *gorm.DB) error {
+	return tx.DropTable("subscription_states").Error
+}
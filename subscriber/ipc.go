@@ -0,0 +1,53 @@
+package subscriber
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+)
+
+// ipcScheme is the URL scheme of endpoints reached over a Unix domain
+// socket, such as the IPC endpoint of geth: "ipc:///path/geth.ipc".
+const ipcScheme = "ipc://"
+
+// isIpcEndpoint returns true if the endpoint is an IPC socket.
+func isIpcEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, ipcScheme)
+}
+
+// ipcConn is a connection to an IPC endpoint. IPC is a stream of
+// JSON-RPC messages, so each message is read as one JSON value.
+type ipcConn struct {
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+func dialIpc(endpoint string) (*ipcConn, error) {
+	conn, err := net.Dial("unix", strings.TrimPrefix(endpoint, ipcScheme))
+	if err != nil {
+		return nil, err
+	}
+	return &ipcConn{conn: conn, decoder: json.NewDecoder(conn)}, nil
+}
+
+func (c *ipcConn) ReadMessage() ([]byte, error) {
+	var message json.RawMessage
+	if err := c.decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (c *ipcConn) WriteMessage(payload []byte) error {
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+func (c *ipcConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *ipcConn) Close() error {
+	return c.conn.Close()
+}
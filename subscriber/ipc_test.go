@@ -0,0 +1,102 @@
+package subscriber
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveIpc starts a mock IPC endpoint that answers like the mock WS
+// endpoint, with JSON strings since IPC messages are JSON values.
+func serveIpc(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ipc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "geth.ipc")
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				decoder := json.NewDecoder(conn)
+				for {
+					var message json.RawMessage
+					if err := decoder.Decode(&message); err != nil {
+						return
+					}
+					if string(message) == "true" {
+						_, _ = conn.Write([]byte(`"confirmation"`))
+					}
+					_, _ = conn.Write([]byte(`"event"`))
+				}
+			}()
+		}
+	}()
+
+	return ipcScheme + path, func() {
+		_ = l.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestWebsocketSubscriber_SubscribeToEvents_IPC(t *testing.T) {
+	endpoint, cleanup := serveIpc(t)
+	defer cleanup()
+
+	wss := WebsocketSubscriber{Endpoint: endpoint, Manager: TestsMockManager{true}}
+	events := make(chan Event)
+
+	sub, err := wss.SubscribeToEvents(events)
+	if err != nil {
+		t.Errorf("SubscribeToEvents() error = %v", err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	event := <-events
+	if string(event) != `"event"` {
+		t.Errorf("SubscribeToEvents() got unexpected message = %s", event)
+	}
+}
+
+func TestWsCaller_Call_IPC(t *testing.T) {
+	endpoint, cleanup := serveIpc(t)
+	defer cleanup()
+
+	t.Run("reuses connection for calls", func(t *testing.T) {
+		caller := NewCaller(endpoint)
+
+		for i := 0; i < 2; i++ {
+			resp, err := caller.Call([]byte(`false`))
+			if err != nil {
+				t.Errorf("Call() error = %v", err)
+				return
+			}
+			if string(resp) != `"event"` {
+				t.Errorf("Call() got unexpected response = %s", resp)
+				return
+			}
+		}
+	})
+
+	t.Run("fails calling missing socket", func(t *testing.T) {
+		caller := NewCaller(ipcScheme + "/invalid/geth.ipc")
+		if _, err := caller.Call([]byte(`false`)); err == nil {
+			t.Error("Call() expected error, but got nil")
+		}
+	})
+}
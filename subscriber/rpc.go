@@ -100,6 +100,15 @@ func sendPostRequest(url string, body []byte) ([]byte, error) {
 	return ioutil.ReadAll(r.Body)
 }
 
+// rpcCaller sends JSON-RPC payloads as POST requests.
+type rpcCaller struct {
+	endpoint string
+}
+
+func (c rpcCaller) Call(payload []byte) ([]byte, error) {
+	return sendPostRequest(c.endpoint, payload)
+}
+
 func (rpc RpcSubscriber) SubscribeToEvents(channel chan<- Event, confirmation ...interface{}) (ISubscription, error) {
 	fmt.Printf("Using RPC endpoint: %s\n", rpc.Endpoint)
 
@@ -93,3 +93,28 @@ func TestRpcSubscriber_Test(t *testing.T) {
 		})
 	}
 }
+
+func TestRpcCaller_Call(t *testing.T) {
+	t.Run("sends payload to endpoint", func(t *testing.T) {
+		u := *rpcMockUrl
+		u.Path = "/test/caller"
+
+		resp, err := NewCaller(u.String()).Call([]byte(`false`))
+		if err != nil {
+			t.Errorf("Call() error = %v", err)
+			return
+		}
+		if string(resp) != "1" {
+			t.Errorf("Call() got unexpected response = %s", resp)
+		}
+	})
+
+	t.Run("fails on bad status", func(t *testing.T) {
+		u := *rpcMockUrl
+		u.Path = "/fails"
+
+		if _, err := NewCaller(u.String()).Call([]byte(`false`)); err == nil {
+			t.Error("Call() expected error, but got nil")
+		}
+	})
+}
@@ -3,6 +3,8 @@
 // subscribes to.
 package subscriber
 
+import "strings"
+
 // Type holds the connection type for the subscription
 type Type int
 
@@ -46,6 +48,30 @@ type JsonManager interface {
 	ParseTestResponse(data []byte) error
 }
 
+// Backfiller is an optional interface for a JsonManager that can
+// catch up on events missed while it was not connected. WS subscriptions
+// call Backfill every time they (re)connect, after GetTriggerJson() has
+// been sent, and deliver the events before parsing any new message.
+type Backfiller interface {
+	Backfill() []Event
+}
+
+// Caller sends a single JSON-RPC payload to the external
+// endpoint, outside of any active subscription, and returns
+// the response payload.
+type Caller interface {
+	Call(payload []byte) ([]byte, error)
+}
+
+// NewCaller returns a Caller that connects to the endpoint
+// using the transport implied by its URL scheme.
+func NewCaller(endpoint string) Caller {
+	if strings.HasPrefix(endpoint, "ws") || isIpcEndpoint(endpoint) {
+		return &wsCaller{endpoint: endpoint}
+	}
+	return rpcCaller{endpoint: endpoint}
+}
+
 // ISubscription holds the interface for interacting
 // with an active subscription.
 type ISubscription interface {
@@ -4,9 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
+	"sync"
 	"time"
 )
 
+// callTimeout is the time to wait for a response
+// to a payload sent using a wsCaller.
+const callTimeout = 5 * time.Second
+
 // WebsocketSubscriber holds the configuration for
 // a not-yet-active WS subscription.
 type WebsocketSubscriber struct {
@@ -16,7 +21,7 @@ type WebsocketSubscriber struct {
 
 // Test sends a opens a WS connection to the endpoint.
 func (wss WebsocketSubscriber) Test() error {
-	c, _, err := websocket.DefaultDialer.Dial(wss.Endpoint, nil)
+	c, err := dial(wss.Endpoint)
 	if err != nil {
 		return err
 	}
@@ -30,14 +35,14 @@ func (wss WebsocketSubscriber) Test() error {
 	resp := make(chan []byte)
 
 	go func() {
-		_, body, err := c.ReadMessage()
+		body, err := c.ReadMessage()
 		if err != nil {
 			close(resp)
 		}
 		resp <- body
 	}()
 
-	err = c.WriteMessage(websocket.BinaryMessage, testPayload)
+	err = c.WriteMessage(testPayload)
 	if err != nil {
 		return err
 	}
@@ -57,8 +62,59 @@ func (wss WebsocketSubscriber) Test() error {
 	}
 }
 
+// messageConn is a connection exchanging whole
+// JSON-RPC messages, over either WebSocket or IPC.
+type messageConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(payload []byte) error
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// dial opens a WS connection to the endpoint, or an IPC
+// connection if the endpoint is a Unix domain socket.
+func dial(endpoint string) (messageConn, error) {
+	if isIpcEndpoint(endpoint) {
+		c, err := dialIpc(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	c, _, err := websocket.DefaultDialer.Dial(endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return websocketConn{c}, nil
+}
+
+// websocketConn is a messageConn over WebSocket.
+type websocketConn struct {
+	conn *websocket.Conn
+}
+
+func (c websocketConn) ReadMessage() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	return message, err
+}
+
+func (c websocketConn) WriteMessage(payload []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+func (c websocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// Close notifies the endpoint before closing the connection.
+func (c websocketConn) Close() error {
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
+
 type wsConn struct {
-	connection *websocket.Conn
+	connection messageConn
 	closing    bool
 }
 
@@ -73,7 +129,6 @@ type WebsocketSubscription struct {
 func (wss WebsocketSubscription) Unsubscribe() {
 	fmt.Println("Unsubscribing from WS endpoint", wss.endpoint)
 	wss.conn.closing = true
-	_ = wss.conn.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 	_ = wss.conn.connection.Close()
 }
 
@@ -83,8 +138,14 @@ func (wss WebsocketSubscription) forceClose() {
 }
 
 func (wss WebsocketSubscription) readMessages() {
+	if backfiller, ok := wss.manager.(Backfiller); ok {
+		for _, event := range backfiller.Backfill() {
+			wss.events <- event
+		}
+	}
+
 	for {
-		_, message, err := wss.conn.connection.ReadMessage()
+		message, err := wss.conn.connection.ReadMessage()
 		if err != nil {
 			_ = wss.conn.connection.Close()
 			if !wss.conn.closing {
@@ -113,22 +174,24 @@ func (wss WebsocketSubscription) readMessages() {
 }
 
 func (wss WebsocketSubscription) init() {
-	go wss.readMessages()
-
-	err := wss.conn.connection.WriteMessage(websocket.TextMessage, wss.manager.GetTriggerJson())
+	// The subscription is opened before reading, so that any
+	// backfill covers everything up until the new subscription.
+	err := wss.conn.connection.WriteMessage(wss.manager.GetTriggerJson())
 	if err != nil {
+		// Reading from the closed connection fails and reconnects
 		wss.forceClose()
-		return
+	} else {
+		fmt.Printf("Connected to %s\n", wss.endpoint)
 	}
 
-	fmt.Printf("Connected to %s\n", wss.endpoint)
+	go wss.readMessages()
 }
 
 func (wss WebsocketSubscription) reconnect() {
 	fmt.Printf("Lost WS connection to %s\nRetrying in %vs\n", wss.endpoint, 3)
 	time.Sleep(3 * time.Second)
 
-	c, _, err := websocket.DefaultDialer.Dial(wss.endpoint, nil)
+	c, err := dial(wss.endpoint)
 	if err != nil {
 		fmt.Println("Reconnect failed:", err)
 		wss.reconnect()
@@ -142,7 +205,7 @@ func (wss WebsocketSubscription) reconnect() {
 func (wss WebsocketSubscriber) SubscribeToEvents(channel chan<- Event, confirmation ...interface{}) (ISubscription, error) {
 	fmt.Printf("Connecting to WS endpoint: %s\n", wss.Endpoint)
 
-	c, _, err := websocket.DefaultDialer.Dial(wss.Endpoint, nil)
+	c, err := dial(wss.Endpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -158,3 +221,47 @@ func (wss WebsocketSubscriber) SubscribeToEvents(channel chan<- Event, confirmat
 
 	return subscription, nil
 }
+
+// wsCaller sends JSON-RPC payloads over a dedicated WS or IPC connection,
+// separate from the subscription, so responses are never mixed
+// with subscription messages. The connection is opened on the
+// first call, and re-opened on the next call after any failure.
+type wsCaller struct {
+	endpoint string
+	mutex    sync.Mutex
+	conn     messageConn
+}
+
+func (c *wsCaller) Call(payload []byte) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		conn, err := dial(c.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	resp, err := c.call(payload)
+	if err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (c *wsCaller) call(payload []byte) ([]byte, error) {
+	if err := c.conn.WriteMessage(payload); err != nil {
+		return nil, err
+	}
+
+	if err := c.conn.SetReadDeadline(time.Now().Add(callTimeout)); err != nil {
+		return nil, err
+	}
+
+	return c.conn.ReadMessage()
+}
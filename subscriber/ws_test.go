@@ -65,6 +65,26 @@ func TestWebsocketSubscriber_SubscribeToEvents(t *testing.T) {
 		}
 	})
 
+	t.Run("sends backfilled events before new events", func(t *testing.T) {
+		wss := WebsocketSubscriber{Endpoint: wsMockUrl.String(), Manager: TestsBackfillManager{TestsMockManager{true}}}
+		events := make(chan Event)
+
+		sub, err := wss.SubscribeToEvents(events)
+		if err != nil {
+			t.Errorf("SubscribeToEvents() error = %v", err)
+			return
+		}
+		defer sub.Unsubscribe()
+
+		for _, expected := range []string{"backfill", "event"} {
+			event := <-events
+			if string(event) != expected {
+				t.Errorf("SubscribeToEvents() got unexpected message = %s, expected %s", event, expected)
+				return
+			}
+		}
+	})
+
 	t.Run("subscribes and attempts reconnect", func(t *testing.T) {
 		wss := WebsocketSubscriber{Endpoint: wsMockUrl.String(), Manager: &TestsReconnectManager{}}
 		events := make(chan Event)
@@ -86,6 +106,14 @@ func TestWebsocketSubscriber_SubscribeToEvents(t *testing.T) {
 	})
 }
 
+type TestsBackfillManager struct {
+	TestsMockManager
+}
+
+func (m TestsBackfillManager) Backfill() []Event {
+	return []Event{[]byte("backfill")}
+}
+
 type TestsReconnectManager struct {
 	connections int
 }
@@ -145,3 +173,28 @@ func TestWebsocketSubscriber_Test(t *testing.T) {
 		})
 	}
 }
+
+func TestWsCaller_Call(t *testing.T) {
+	t.Run("reuses connection for calls", func(t *testing.T) {
+		caller := NewCaller(wsMockUrl.String())
+
+		for i := 0; i < 2; i++ {
+			resp, err := caller.Call([]byte(`false`))
+			if err != nil {
+				t.Errorf("Call() error = %v", err)
+				return
+			}
+			if string(resp) != "event" {
+				t.Errorf("Call() got unexpected response = %s", resp)
+				return
+			}
+		}
+	})
+
+	t.Run("fails calling invalid endpoint", func(t *testing.T) {
+		caller := NewCaller("ws://localhost:9999/invalid")
+		if _, err := caller.Call([]byte(`false`)); err == nil {
+			t.Error("Call() expected error, but got nil")
+		}
+	})
+}